@@ -40,3 +40,16 @@ func (s *FollowerServer) GetFollowers(ctx context.Context, req *pb.GetFollowersR
 		Followers: pbFollowers,
 	}, nil
 }
+
+func (s *FollowerServer) IsFollower(ctx context.Context, req *pb.IsFollowerRequest) (*pb.IsFollowerResponse, error) {
+	if req.UserId == "" || req.FollowerId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and follower_id required")
+	}
+
+	follows, err := s.repo.IsFollower(ctx, req.UserId, req.FollowerId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to check follower: "+err.Error())
+	}
+
+	return &pb.IsFollowerResponse{IsFollower: follows}, nil
+}
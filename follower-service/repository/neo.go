@@ -91,6 +91,30 @@ func (r *NeoRepository) Followers(ctx context.Context, userID string) ([]string,
     return res.([]string), nil
 }
 
+func (r *NeoRepository) IsFollower(ctx context.Context, userID, followerID string) (bool, error) {
+    sess := r.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer sess.Close(ctx)
+    res, err := sess.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        cy := `OPTIONAL MATCH (f:User {id:$follower})-[rel:FOLLOWS]->(u:User {id:$id}) RETURN count(rel) > 0 as follows`
+        params := map[string]any{"id": userID, "follower": followerID}
+        rec, err := tx.Run(ctx, cy, params)
+        if err != nil {
+            return false, err
+        }
+        follows := false
+        if rec.Next(ctx) {
+            if v, ok := rec.Record().Values[0].(bool); ok {
+                follows = v
+            }
+        }
+        return follows, rec.Err()
+    })
+    if err != nil {
+        return false, err
+    }
+    return res.(bool), nil
+}
+
 func (r *NeoRepository) Following(ctx context.Context, userID string) ([]string, error) {
     sess := r.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
     defer sess.Close(ctx)
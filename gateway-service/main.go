@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"time"
 
@@ -110,6 +111,19 @@ func newProxy(target string) *httputil.ReverseProxy {
 	return proxy
 }
 
+// isUpgradeRequest reports whether the client asks to switch protocols, e.g. to a WebSocket.
+// httputil.ReverseProxy tunnels such connections itself once the backend answers 101.
+func isUpgradeRequest(r *http.Request) bool {
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return r.Header.Get("Upgrade") != ""
+			}
+		}
+	}
+	return false
+}
+
 // gRPC client connections
 type grpcClients struct {
 	stakeholderConn   *grpc.ClientConn
@@ -245,6 +259,8 @@ func main() {
 		"/kp":         "http://tour-service:8083",
 		"/keypoints":  "http://tour-service:8083",
 		"/executions": "http://tour-service:8083",
+		"/groups":     "http://tour-service:8083",
+		"/shared":     "http://tour-service:8083",
 
 		// stakeholders (users/auth) - register still via HTTP
 		"/health":   "http://stakeholders-service:8080",
@@ -384,6 +400,7 @@ func main() {
 				"driving": resp.Tour.Durations.Driving,
 			},
 			"createdAt": resp.Tour.CreatedAt,
+			"rating":    ratingJSON(resp.Tour.Rating),
 		}
 		if resp.Tour.PublishedAt != "" {
 			tourJSON["publishedAt"] = resp.Tour.PublishedAt
@@ -454,6 +471,7 @@ func main() {
 					"driving": tour.Durations.Driving,
 				},
 				"createdAt": tour.CreatedAt,
+				"rating":    ratingJSON(tour.Rating),
 			}
 			if tour.PublishedAt != "" {
 				tourJSON["publishedAt"] = tour.PublishedAt
@@ -536,7 +554,8 @@ func main() {
 			return
 		}
 
-		if r.Method == "GET" && strings.HasPrefix(path, "/tours/") && len(strings.Split(strings.Trim(path, "/"), "/")) == 2 {
+		// /tours/nearby has the same shape as /tours/{id} but is a search, so it goes through the HTTP proxy
+		if r.Method == "GET" && strings.HasPrefix(path, "/tours/") && path != "/tours/nearby" && len(strings.Split(strings.Trim(path, "/"), "/")) == 2 {
 			handleGetTourByID(w, r, grpcClients.tourClient)
 			return
 		}
@@ -553,6 +572,12 @@ func main() {
 			}
 		}
 		if matchedTarget != "" {
+			if isUpgradeRequest(r) {
+				// the upgraded connection is handed over to the proxy and must outlive the server timeouts
+				rc := http.NewResponseController(w)
+				_ = rc.SetReadDeadline(time.Time{})
+				_ = rc.SetWriteDeadline(time.Time{})
+			}
 			proxies[matchedTarget].ServeHTTP(w, r)
 			return
 		}
@@ -607,3 +632,20 @@ func main() {
 		"action":  "shutdown",
 	}).Info("Server shutdown complete")
 }
+
+// ratingJSON renders a tour's review aggregate the way tour-service does over REST
+func ratingJSON(r *pb.RatingSummary) map[string]interface{} {
+	histogram := map[string]int32{}
+	for i := 0; i < 5; i++ {
+		var n int32
+		if r != nil && i < len(r.Histogram) {
+			n = r.Histogram[i]
+		}
+		histogram[strconv.Itoa(i+1)] = n
+	}
+	return map[string]interface{}{
+		"average":   r.GetAverage(),
+		"count":     r.GetCount(),
+		"histogram": histogram,
+	}
+}
@@ -156,6 +156,104 @@ func (x *GetFollowersResponse) GetFollowers() []*Follower {
 	return nil
 }
 
+// Zahtev za proveru da li jedan korisnik prati drugog
+type IsFollowerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FollowerId    string                 `protobuf:"bytes,2,opt,name=follower_id,json=followerId,proto3" json:"follower_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IsFollowerRequest) Reset() {
+	*x = IsFollowerRequest{}
+	mi := &file_protos_follower_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IsFollowerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsFollowerRequest) ProtoMessage() {}
+
+func (x *IsFollowerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protos_follower_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsFollowerRequest.ProtoReflect.Descriptor instead.
+func (*IsFollowerRequest) Descriptor() ([]byte, []int) {
+	return file_protos_follower_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *IsFollowerRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *IsFollowerRequest) GetFollowerId() string {
+	if x != nil {
+		return x.FollowerId
+	}
+	return ""
+}
+
+// Odgovor na proveru praćenja
+type IsFollowerResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IsFollower    bool                   `protobuf:"varint,1,opt,name=is_follower,json=isFollower,proto3" json:"is_follower,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IsFollowerResponse) Reset() {
+	*x = IsFollowerResponse{}
+	mi := &file_protos_follower_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IsFollowerResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsFollowerResponse) ProtoMessage() {}
+
+func (x *IsFollowerResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_protos_follower_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsFollowerResponse.ProtoReflect.Descriptor instead.
+func (*IsFollowerResponse) Descriptor() ([]byte, []int) {
+	return file_protos_follower_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *IsFollowerResponse) GetIsFollower() bool {
+	if x != nil {
+		return x.IsFollower
+	}
+	return false
+}
+
 var File_protos_follower_proto protoreflect.FileDescriptor
 
 const file_protos_follower_proto_rawDesc = "" +
@@ -166,9 +264,18 @@ const file_protos_follower_proto_rawDesc = "" +
 	"\bFollower\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\"H\n" +
 	"\x14GetFollowersResponse\x120\n" +
-	"\tfollowers\x18\x01 \x03(\v2\x12.follower.FollowerR\tfollowers2`\n" +
+	"\tfollowers\x18\x01 \x03(\v2\x12.follower.FollowerR\tfollowers\"M\n" +
+	"\x11IsFollowerRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1f\n" +
+	"\vfollower_id\x18\x02 \x01(\tR\n" +
+	"followerId\"5\n" +
+	"\x12IsFollowerResponse\x12\x1f\n" +
+	"\vis_follower\x18\x01 \x01(\bR\n" +
+	"isFollower2\xa9\x01\n" +
 	"\x0fFollowerService\x12M\n" +
-	"\fGetFollowers\x12\x1d.follower.GetFollowersRequest\x1a\x1e.follower.GetFollowersResponseB*Z(github.com/IvanNovakovic/SOA_Proj/protosb\x06proto3"
+	"\fGetFollowers\x12\x1d.follower.GetFollowersRequest\x1a\x1e.follower.GetFollowersResponse\x12G\n" +
+	"\n" +
+	"IsFollower\x12\x1b.follower.IsFollowerRequest\x1a\x1c.follower.IsFollowerResponseB*Z(github.com/IvanNovakovic/SOA_Proj/protosb\x06proto3"
 
 var (
 	file_protos_follower_proto_rawDescOnce sync.Once
@@ -182,18 +289,22 @@ func file_protos_follower_proto_rawDescGZIP() []byte {
 	return file_protos_follower_proto_rawDescData
 }
 
-var file_protos_follower_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_protos_follower_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_protos_follower_proto_goTypes = []any{
 	(*GetFollowersRequest)(nil),  // 0: follower.GetFollowersRequest
 	(*Follower)(nil),             // 1: follower.Follower
 	(*GetFollowersResponse)(nil), // 2: follower.GetFollowersResponse
+	(*IsFollowerRequest)(nil),    // 3: follower.IsFollowerRequest
+	(*IsFollowerResponse)(nil),   // 4: follower.IsFollowerResponse
 }
 var file_protos_follower_proto_depIdxs = []int32{
 	1, // 0: follower.GetFollowersResponse.followers:type_name -> follower.Follower
 	0, // 1: follower.FollowerService.GetFollowers:input_type -> follower.GetFollowersRequest
-	2, // 2: follower.FollowerService.GetFollowers:output_type -> follower.GetFollowersResponse
-	2, // [2:3] is the sub-list for method output_type
-	1, // [1:2] is the sub-list for method input_type
+	3, // 2: follower.FollowerService.IsFollower:input_type -> follower.IsFollowerRequest
+	2, // 3: follower.FollowerService.GetFollowers:output_type -> follower.GetFollowersResponse
+	4, // 4: follower.FollowerService.IsFollower:output_type -> follower.IsFollowerResponse
+	3, // [3:5] is the sub-list for method output_type
+	1, // [1:3] is the sub-list for method input_type
 	1, // [1:1] is the sub-list for extension type_name
 	1, // [1:1] is the sub-list for extension extendee
 	0, // [0:1] is the sub-list for field type_name
@@ -210,7 +321,7 @@ func file_protos_follower_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_protos_follower_proto_rawDesc), len(file_protos_follower_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -20,6 +20,7 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	FollowerService_GetFollowers_FullMethodName = "/follower.FollowerService/GetFollowers"
+	FollowerService_IsFollower_FullMethodName   = "/follower.FollowerService/IsFollower"
 )
 
 // FollowerServiceClient is the client API for FollowerService service.
@@ -30,6 +31,8 @@ const (
 type FollowerServiceClient interface {
 	// Dobijanje liste pratilaca za korisnika
 	GetFollowers(ctx context.Context, in *GetFollowersRequest, opts ...grpc.CallOption) (*GetFollowersResponse, error)
+	// Provera da li follower_id prati user_id, bez učitavanja cele liste
+	IsFollower(ctx context.Context, in *IsFollowerRequest, opts ...grpc.CallOption) (*IsFollowerResponse, error)
 }
 
 type followerServiceClient struct {
@@ -50,6 +53,16 @@ func (c *followerServiceClient) GetFollowers(ctx context.Context, in *GetFollowe
 	return out, nil
 }
 
+func (c *followerServiceClient) IsFollower(ctx context.Context, in *IsFollowerRequest, opts ...grpc.CallOption) (*IsFollowerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IsFollowerResponse)
+	err := c.cc.Invoke(ctx, FollowerService_IsFollower_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // FollowerServiceServer is the server API for FollowerService service.
 // All implementations must embed UnimplementedFollowerServiceServer
 // for forward compatibility.
@@ -58,6 +71,8 @@ func (c *followerServiceClient) GetFollowers(ctx context.Context, in *GetFollowe
 type FollowerServiceServer interface {
 	// Dobijanje liste pratilaca za korisnika
 	GetFollowers(context.Context, *GetFollowersRequest) (*GetFollowersResponse, error)
+	// Provera da li follower_id prati user_id, bez učitavanja cele liste
+	IsFollower(context.Context, *IsFollowerRequest) (*IsFollowerResponse, error)
 	mustEmbedUnimplementedFollowerServiceServer()
 }
 
@@ -71,6 +86,9 @@ type UnimplementedFollowerServiceServer struct{}
 func (UnimplementedFollowerServiceServer) GetFollowers(context.Context, *GetFollowersRequest) (*GetFollowersResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetFollowers not implemented")
 }
+func (UnimplementedFollowerServiceServer) IsFollower(context.Context, *IsFollowerRequest) (*IsFollowerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IsFollower not implemented")
+}
 func (UnimplementedFollowerServiceServer) mustEmbedUnimplementedFollowerServiceServer() {}
 func (UnimplementedFollowerServiceServer) testEmbeddedByValue()                         {}
 
@@ -110,6 +128,24 @@ func _FollowerService_GetFollowers_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _FollowerService_IsFollower_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IsFollowerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FollowerServiceServer).IsFollower(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FollowerService_IsFollower_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FollowerServiceServer).IsFollower(ctx, req.(*IsFollowerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // FollowerService_ServiceDesc is the grpc.ServiceDesc for FollowerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -121,6 +157,10 @@ var FollowerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetFollowers",
 			Handler:    _FollowerService_GetFollowers_Handler,
 		},
+		{
+			MethodName: "IsFollower",
+			Handler:    _FollowerService_IsFollower_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "protos/follower.proto",
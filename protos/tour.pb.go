@@ -188,6 +188,7 @@ type Tour struct {
 	PublishedAt   string                 `protobuf:"bytes,11,opt,name=published_at,json=publishedAt,proto3" json:"published_at,omitempty"`
 	ArchivedAt    string                 `protobuf:"bytes,12,opt,name=archived_at,json=archivedAt,proto3" json:"archived_at,omitempty"`
 	CreatedAt     string                 `protobuf:"bytes,13,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Rating        *RatingSummary         `protobuf:"bytes,14,opt,name=rating,proto3" json:"rating,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -313,6 +314,74 @@ func (x *Tour) GetCreatedAt() string {
 	return ""
 }
 
+func (x *Tour) GetRating() *RatingSummary {
+	if x != nil {
+		return x.Rating
+	}
+	return nil
+}
+
+// Review aggregate of a tour; histogram holds the number of 1..5 star reviews
+type RatingSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Average       float64                `protobuf:"fixed64,1,opt,name=average,proto3" json:"average,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Histogram     []int32                `protobuf:"varint,3,rep,packed,name=histogram,proto3" json:"histogram,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RatingSummary) Reset() {
+	*x = RatingSummary{}
+	mi := &file_tour_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RatingSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RatingSummary) ProtoMessage() {}
+
+func (x *RatingSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_tour_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RatingSummary.ProtoReflect.Descriptor instead.
+func (*RatingSummary) Descriptor() ([]byte, []int) {
+	return file_tour_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RatingSummary) GetAverage() float64 {
+	if x != nil {
+		return x.Average
+	}
+	return 0
+}
+
+func (x *RatingSummary) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *RatingSummary) GetHistogram() []int32 {
+	if x != nil {
+		return x.Histogram
+	}
+	return nil
+}
+
 // Response for getting tour by ID
 type GetTourByIDResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -323,7 +392,7 @@ type GetTourByIDResponse struct {
 
 func (x *GetTourByIDResponse) Reset() {
 	*x = GetTourByIDResponse{}
-	mi := &file_tour_proto_msgTypes[4]
+	mi := &file_tour_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -335,7 +404,7 @@ func (x *GetTourByIDResponse) String() string {
 func (*GetTourByIDResponse) ProtoMessage() {}
 
 func (x *GetTourByIDResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tour_proto_msgTypes[4]
+	mi := &file_tour_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -348,7 +417,7 @@ func (x *GetTourByIDResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetTourByIDResponse.ProtoReflect.Descriptor instead.
 func (*GetTourByIDResponse) Descriptor() ([]byte, []int) {
-	return file_tour_proto_rawDescGZIP(), []int{4}
+	return file_tour_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *GetTourByIDResponse) GetTour() *Tour {
@@ -368,7 +437,7 @@ type GetToursByAuthorResponse struct {
 
 func (x *GetToursByAuthorResponse) Reset() {
 	*x = GetToursByAuthorResponse{}
-	mi := &file_tour_proto_msgTypes[5]
+	mi := &file_tour_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -380,7 +449,7 @@ func (x *GetToursByAuthorResponse) String() string {
 func (*GetToursByAuthorResponse) ProtoMessage() {}
 
 func (x *GetToursByAuthorResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tour_proto_msgTypes[5]
+	mi := &file_tour_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -393,7 +462,7 @@ func (x *GetToursByAuthorResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetToursByAuthorResponse.ProtoReflect.Descriptor instead.
 func (*GetToursByAuthorResponse) Descriptor() ([]byte, []int) {
-	return file_tour_proto_rawDescGZIP(), []int{5}
+	return file_tour_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *GetToursByAuthorResponse) GetTours() []*Tour {
@@ -403,6 +472,189 @@ func (x *GetToursByAuthorResponse) GetTours() []*Tour {
 	return nil
 }
 
+// Request for searching published tours around a point
+type SearchNearbyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Latitude      float64                `protobuf:"fixed64,1,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude     float64                `protobuf:"fixed64,2,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	Radius        float64                `protobuf:"fixed64,3,opt,name=radius,proto3" json:"radius,omitempty"` // meters
+	Mode          string                 `protobuf:"bytes,4,opt,name=mode,proto3" json:"mode,omitempty"`       // "start" (first key point, default) or "any" (closest key point)
+	Limit         int32                  `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchNearbyRequest) Reset() {
+	*x = SearchNearbyRequest{}
+	mi := &file_tour_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchNearbyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchNearbyRequest) ProtoMessage() {}
+
+func (x *SearchNearbyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tour_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchNearbyRequest.ProtoReflect.Descriptor instead.
+func (*SearchNearbyRequest) Descriptor() ([]byte, []int) {
+	return file_tour_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SearchNearbyRequest) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *SearchNearbyRequest) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *SearchNearbyRequest) GetRadius() float64 {
+	if x != nil {
+		return x.Radius
+	}
+	return 0
+}
+
+func (x *SearchNearbyRequest) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *SearchNearbyRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+// Tour found by a nearby search
+type NearbyTour struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tour          *Tour                  `protobuf:"bytes,1,opt,name=tour,proto3" json:"tour,omitempty"`
+	Distance      float64                `protobuf:"fixed64,2,opt,name=distance,proto3" json:"distance,omitempty"` // meters to the matched key point
+	KeyPointId    string                 `protobuf:"bytes,3,opt,name=key_point_id,json=keyPointId,proto3" json:"key_point_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NearbyTour) Reset() {
+	*x = NearbyTour{}
+	mi := &file_tour_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NearbyTour) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NearbyTour) ProtoMessage() {}
+
+func (x *NearbyTour) ProtoReflect() protoreflect.Message {
+	mi := &file_tour_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NearbyTour.ProtoReflect.Descriptor instead.
+func (*NearbyTour) Descriptor() ([]byte, []int) {
+	return file_tour_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *NearbyTour) GetTour() *Tour {
+	if x != nil {
+		return x.Tour
+	}
+	return nil
+}
+
+func (x *NearbyTour) GetDistance() float64 {
+	if x != nil {
+		return x.Distance
+	}
+	return 0
+}
+
+func (x *NearbyTour) GetKeyPointId() string {
+	if x != nil {
+		return x.KeyPointId
+	}
+	return ""
+}
+
+// Response for nearby search, closest tours first
+type SearchNearbyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tours         []*NearbyTour          `protobuf:"bytes,1,rep,name=tours,proto3" json:"tours,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchNearbyResponse) Reset() {
+	*x = SearchNearbyResponse{}
+	mi := &file_tour_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchNearbyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchNearbyResponse) ProtoMessage() {}
+
+func (x *SearchNearbyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tour_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchNearbyResponse.ProtoReflect.Descriptor instead.
+func (*SearchNearbyResponse) Descriptor() ([]byte, []int) {
+	return file_tour_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SearchNearbyResponse) GetTours() []*NearbyTour {
+	if x != nil {
+		return x.Tours
+	}
+	return nil
+}
+
 var File_tour_proto protoreflect.FileDescriptor
 
 const file_tour_proto_rawDesc = "" +
@@ -416,7 +668,7 @@ const file_tour_proto_rawDesc = "" +
 	"\x11TransportDuration\x12\x18\n" +
 	"\awalking\x18\x01 \x01(\x05R\awalking\x12\x16\n" +
 	"\x06biking\x18\x02 \x01(\x05R\x06biking\x12\x18\n" +
-	"\adriving\x18\x03 \x01(\x05R\adriving\"\x81\x03\n" +
+	"\adriving\x18\x03 \x01(\x05R\adriving\"\xae\x03\n" +
 	"\x04Tour\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
 	"\tauthor_id\x18\x02 \x01(\tR\bauthorId\x12\x12\n" +
@@ -435,16 +687,37 @@ const file_tour_proto_rawDesc = "" +
 	"\varchived_at\x18\f \x01(\tR\n" +
 	"archivedAt\x12\x1d\n" +
 	"\n" +
-	"created_at\x18\r \x01(\tR\tcreatedAt\"5\n" +
+	"created_at\x18\r \x01(\tR\tcreatedAt\x12+\n" +
+	"\x06rating\x18\x0e \x01(\v2\x13.tour.RatingSummaryR\x06rating\"]\n" +
+	"\rRatingSummary\x12\x18\n" +
+	"\aaverage\x18\x01 \x01(\x01R\aaverage\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\x12\x1c\n" +
+	"\thistogram\x18\x03 \x03(\x05R\thistogram\"5\n" +
 	"\x13GetTourByIDResponse\x12\x1e\n" +
 	"\x04tour\x18\x01 \x01(\v2\n" +
 	".tour.TourR\x04tour\"<\n" +
 	"\x18GetToursByAuthorResponse\x12 \n" +
 	"\x05tours\x18\x01 \x03(\v2\n" +
-	".tour.TourR\x05tours2\xa4\x01\n" +
+	".tour.TourR\x05tours\"\x91\x01\n" +
+	"\x13SearchNearbyRequest\x12\x1a\n" +
+	"\blatitude\x18\x01 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\x02 \x01(\x01R\tlongitude\x12\x16\n" +
+	"\x06radius\x18\x03 \x01(\x01R\x06radius\x12\x12\n" +
+	"\x04mode\x18\x04 \x01(\tR\x04mode\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\"j\n" +
+	"\n" +
+	"NearbyTour\x12\x1e\n" +
+	"\x04tour\x18\x01 \x01(\v2\n" +
+	".tour.TourR\x04tour\x12\x1a\n" +
+	"\bdistance\x18\x02 \x01(\x01R\bdistance\x12 \n" +
+	"\fkey_point_id\x18\x03 \x01(\tR\n" +
+	"keyPointId\">\n" +
+	"\x14SearchNearbyResponse\x12&\n" +
+	"\x05tours\x18\x01 \x03(\v2\x10.tour.NearbyTourR\x05tours2\xeb\x01\n" +
 	"\vTourService\x12B\n" +
 	"\vGetTourByID\x12\x18.tour.GetTourByIDRequest\x1a\x19.tour.GetTourByIDResponse\x12Q\n" +
-	"\x10GetToursByAuthor\x12\x1d.tour.GetToursByAuthorRequest\x1a\x1e.tour.GetToursByAuthorResponseB*Z(github.com/IvanNovakovic/SOA_Proj/protosb\x06proto3"
+	"\x10GetToursByAuthor\x12\x1d.tour.GetToursByAuthorRequest\x1a\x1e.tour.GetToursByAuthorResponse\x12E\n" +
+	"\fSearchNearby\x12\x19.tour.SearchNearbyRequest\x1a\x1a.tour.SearchNearbyResponseB*Z(github.com/IvanNovakovic/SOA_Proj/protosb\x06proto3"
 
 var (
 	file_tour_proto_rawDescOnce sync.Once
@@ -458,28 +731,37 @@ func file_tour_proto_rawDescGZIP() []byte {
 	return file_tour_proto_rawDescData
 }
 
-var file_tour_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_tour_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_tour_proto_goTypes = []any{
 	(*GetTourByIDRequest)(nil),       // 0: tour.GetTourByIDRequest
 	(*GetToursByAuthorRequest)(nil),  // 1: tour.GetToursByAuthorRequest
 	(*TransportDuration)(nil),        // 2: tour.TransportDuration
 	(*Tour)(nil),                     // 3: tour.Tour
-	(*GetTourByIDResponse)(nil),      // 4: tour.GetTourByIDResponse
-	(*GetToursByAuthorResponse)(nil), // 5: tour.GetToursByAuthorResponse
+	(*RatingSummary)(nil),            // 4: tour.RatingSummary
+	(*GetTourByIDResponse)(nil),      // 5: tour.GetTourByIDResponse
+	(*GetToursByAuthorResponse)(nil), // 6: tour.GetToursByAuthorResponse
+	(*SearchNearbyRequest)(nil),      // 7: tour.SearchNearbyRequest
+	(*NearbyTour)(nil),               // 8: tour.NearbyTour
+	(*SearchNearbyResponse)(nil),     // 9: tour.SearchNearbyResponse
 }
 var file_tour_proto_depIdxs = []int32{
 	2, // 0: tour.Tour.durations:type_name -> tour.TransportDuration
-	3, // 1: tour.GetTourByIDResponse.tour:type_name -> tour.Tour
-	3, // 2: tour.GetToursByAuthorResponse.tours:type_name -> tour.Tour
-	0, // 3: tour.TourService.GetTourByID:input_type -> tour.GetTourByIDRequest
-	1, // 4: tour.TourService.GetToursByAuthor:input_type -> tour.GetToursByAuthorRequest
-	4, // 5: tour.TourService.GetTourByID:output_type -> tour.GetTourByIDResponse
-	5, // 6: tour.TourService.GetToursByAuthor:output_type -> tour.GetToursByAuthorResponse
-	5, // [5:7] is the sub-list for method output_type
-	3, // [3:5] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	4, // 1: tour.Tour.rating:type_name -> tour.RatingSummary
+	3, // 2: tour.GetTourByIDResponse.tour:type_name -> tour.Tour
+	3, // 3: tour.GetToursByAuthorResponse.tours:type_name -> tour.Tour
+	3, // 4: tour.NearbyTour.tour:type_name -> tour.Tour
+	8, // 5: tour.SearchNearbyResponse.tours:type_name -> tour.NearbyTour
+	0, // 6: tour.TourService.GetTourByID:input_type -> tour.GetTourByIDRequest
+	1, // 7: tour.TourService.GetToursByAuthor:input_type -> tour.GetToursByAuthorRequest
+	7, // 8: tour.TourService.SearchNearby:input_type -> tour.SearchNearbyRequest
+	5, // 9: tour.TourService.GetTourByID:output_type -> tour.GetTourByIDResponse
+	6, // 10: tour.TourService.GetToursByAuthor:output_type -> tour.GetToursByAuthorResponse
+	9, // 11: tour.TourService.SearchNearby:output_type -> tour.SearchNearbyResponse
+	9, // [9:12] is the sub-list for method output_type
+	6, // [6:9] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_tour_proto_init() }
@@ -493,7 +775,7 @@ func file_tour_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tour_proto_rawDesc), len(file_tour_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   10,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -21,6 +21,7 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	TourService_GetTourByID_FullMethodName      = "/tour.TourService/GetTourByID"
 	TourService_GetToursByAuthor_FullMethodName = "/tour.TourService/GetToursByAuthor"
+	TourService_SearchNearby_FullMethodName     = "/tour.TourService/SearchNearby"
 )
 
 // TourServiceClient is the client API for TourService service.
@@ -33,6 +34,8 @@ type TourServiceClient interface {
 	GetTourByID(ctx context.Context, in *GetTourByIDRequest, opts ...grpc.CallOption) (*GetTourByIDResponse, error)
 	// Get tours by author
 	GetToursByAuthor(ctx context.Context, in *GetToursByAuthorRequest, opts ...grpc.CallOption) (*GetToursByAuthorResponse, error)
+	// Search published tours near a point
+	SearchNearby(ctx context.Context, in *SearchNearbyRequest, opts ...grpc.CallOption) (*SearchNearbyResponse, error)
 }
 
 type tourServiceClient struct {
@@ -63,6 +66,16 @@ func (c *tourServiceClient) GetToursByAuthor(ctx context.Context, in *GetToursBy
 	return out, nil
 }
 
+func (c *tourServiceClient) SearchNearby(ctx context.Context, in *SearchNearbyRequest, opts ...grpc.CallOption) (*SearchNearbyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchNearbyResponse)
+	err := c.cc.Invoke(ctx, TourService_SearchNearby_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TourServiceServer is the server API for TourService service.
 // All implementations must embed UnimplementedTourServiceServer
 // for forward compatibility.
@@ -73,6 +86,8 @@ type TourServiceServer interface {
 	GetTourByID(context.Context, *GetTourByIDRequest) (*GetTourByIDResponse, error)
 	// Get tours by author
 	GetToursByAuthor(context.Context, *GetToursByAuthorRequest) (*GetToursByAuthorResponse, error)
+	// Search published tours near a point
+	SearchNearby(context.Context, *SearchNearbyRequest) (*SearchNearbyResponse, error)
 	mustEmbedUnimplementedTourServiceServer()
 }
 
@@ -89,6 +104,9 @@ func (UnimplementedTourServiceServer) GetTourByID(context.Context, *GetTourByIDR
 func (UnimplementedTourServiceServer) GetToursByAuthor(context.Context, *GetToursByAuthorRequest) (*GetToursByAuthorResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetToursByAuthor not implemented")
 }
+func (UnimplementedTourServiceServer) SearchNearby(context.Context, *SearchNearbyRequest) (*SearchNearbyResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SearchNearby not implemented")
+}
 func (UnimplementedTourServiceServer) mustEmbedUnimplementedTourServiceServer() {}
 func (UnimplementedTourServiceServer) testEmbeddedByValue()                     {}
 
@@ -146,6 +164,24 @@ func _TourService_GetToursByAuthor_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TourService_SearchNearby_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchNearbyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TourServiceServer).SearchNearby(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TourService_SearchNearby_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TourServiceServer).SearchNearby(ctx, req.(*SearchNearbyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // TourService_ServiceDesc is the grpc.ServiceDesc for TourService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -161,6 +197,10 @@ var TourService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetToursByAuthor",
 			Handler:    _TourService_GetToursByAuthor_Handler,
 		},
+		{
+			MethodName: "SearchNearby",
+			Handler:    _TourService_SearchNearby_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "tour.proto",
@@ -17,6 +17,19 @@ type AuthContext struct {
 	Roles    []string
 }
 
+// HasRole reports whether the token carried the given role, e.g. "admin"
+func (a *AuthContext) HasRole(role string) bool {
+	if a == nil {
+		return false
+	}
+	for _, r := range a.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 type authContextKey struct{}
 
 // Claims mirrors the structure used in stakeholders-service (uid, username, roles)
@@ -49,15 +62,26 @@ func ParseToken(tokenStr string) (*Claims, error) {
 	return nil, errors.New("invalid token")
 }
 
+// bearerToken reads the token from the Authorization header. Browsers cannot set headers on
+// WebSocket handshakes, so upgrade requests may pass it as ?access_token= instead.
+func bearerToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
+
 // JWTAuthMiddleware validates Authorization bearer tokens and injects AuthContext into request context.
 func JWTAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		h := r.Header.Get("Authorization")
-		if !strings.HasPrefix(h, "Bearer ") {
+		token := bearerToken(r)
+		if token == "" {
 			http.Error(w, "missing bearer token", http.StatusUnauthorized)
 			return
 		}
-		token := strings.TrimPrefix(h, "Bearer ")
 		claims, err := ParseToken(token)
 		if err != nil {
 			http.Error(w, "invalid token", http.StatusUnauthorized)
@@ -85,9 +109,7 @@ func GetAuth(r *http.Request) *AuthContext {
 // OptionalAuthMiddleware attempts to parse JWT token if present but doesn't reject requests without tokens
 func OptionalAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		h := r.Header.Get("Authorization")
-		if strings.HasPrefix(h, "Bearer ") {
-			token := strings.TrimPrefix(h, "Bearer ")
+		if token := bearerToken(r); token != "" {
 			claims, err := ParseToken(token)
 			if err == nil {
 				// Valid token - add auth context
@@ -0,0 +1,37 @@
+package grpc
+
+import (
+	"context"
+
+	pb "github.com/IvanNovakovic/SOA_Proj/protos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// FollowerClient asks follower-service who follows whom
+type FollowerClient struct {
+	conn   *grpc.ClientConn
+	client pb.FollowerServiceClient
+}
+
+// NewFollowerClient connects lazily, so follower-service does not have to be up when tour-service starts
+func NewFollowerClient(addr string) (*FollowerClient, error) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return &FollowerClient{conn: conn, client: pb.NewFollowerServiceClient(conn)}, nil
+}
+
+// IsFollower reports whether followerId follows userId
+func (c *FollowerClient) IsFollower(ctx context.Context, userId string, followerId string) (bool, error) {
+	res, err := c.client.IsFollower(ctx, &pb.IsFollowerRequest{UserId: userId, FollowerId: followerId})
+	if err != nil {
+		return false, err
+	}
+	return res.GetIsFollower(), nil
+}
+
+func (c *FollowerClient) Close() error {
+	return c.conn.Close()
+}
@@ -6,8 +6,11 @@ import (
 
 	"tour-service/model"
 	"tour-service/repository"
+	"tour-service/utils"
 
 	pb "github.com/IvanNovakovic/SOA_Proj/protos"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type TourGRPCServer struct {
@@ -51,6 +54,42 @@ func (s *TourGRPCServer) GetToursByAuthor(ctx context.Context, req *pb.GetToursB
 	return &pb.GetToursByAuthorResponse{Tours: pbTours}, nil
 }
 
+// SearchNearby implements the SearchNearby RPC method
+func (s *TourGRPCServer) SearchNearby(ctx context.Context, req *pb.SearchNearbyRequest) (*pb.SearchNearbyResponse, error) {
+	log.Printf("gRPC SearchNearby called with lat: %f, lng: %f, radius: %f", req.Latitude, req.Longitude, req.Radius)
+
+	if !utils.ValidCoordinates(req.Latitude, req.Longitude) {
+		return nil, status.Error(codes.InvalidArgument, "coordinates out of range")
+	}
+	if req.Radius <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "radius must be positive")
+	}
+	mode := req.Mode
+	if mode == "" {
+		mode = model.NearbyStart
+	}
+	if mode != model.NearbyStart && mode != model.NearbyAny {
+		return nil, status.Error(codes.InvalidArgument, "mode must be start or any")
+	}
+
+	tours, err := s.repo.SearchNearby(ctx, req.Latitude, req.Longitude, req.Radius, mode, int(req.Limit))
+	if err != nil {
+		log.Printf("Error searching nearby tours: %v", err)
+		return nil, err
+	}
+
+	var pbTours []*pb.NearbyTour
+	for _, nt := range tours {
+		pbTours = append(pbTours, &pb.NearbyTour{
+			Tour:       convertTourToProto(&nt.Tour),
+			Distance:   nt.Distance,
+			KeyPointId: nt.KeyPointID.Hex(),
+		})
+	}
+
+	return &pb.SearchNearbyResponse{Tours: pbTours}, nil
+}
+
 // Helper function to convert model.Tour to protobuf Tour
 func convertTourToProto(tour *model.Tour) *pb.Tour {
 	pbTour := &pb.Tour{
@@ -60,7 +99,7 @@ func convertTourToProto(tour *model.Tour) *pb.Tour {
 		Description: tour.Description,
 		Difficulty:  tour.Difficulty,
 		Tags:        tour.Tags,
-		Status:      tour.Status,
+		Status:      string(tour.Status),
 		Price:       tour.Price,
 		Distance:    tour.Distance,
 		Durations: &pb.TransportDuration{
@@ -69,6 +108,13 @@ func convertTourToProto(tour *model.Tour) *pb.Tour {
 			Driving: int32(tour.Durations.Driving),
 		},
 		CreatedAt: tour.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Rating: &pb.RatingSummary{
+			Average: tour.Rating.Average,
+			Count:   int32(tour.Rating.Count),
+		},
+	}
+	for _, n := range tour.Rating.Histogram.Counts() {
+		pbTour.Rating.Histogram = append(pbTour.Rating.Histogram, int32(n))
 	}
 
 	if tour.PublishedAt != nil {
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"tour-service/auth"
+	"tour-service/service"
+
+	"github.com/gorilla/mux"
+)
+
+type shareExecutionRequest struct {
+	ExpiresInMinutes int  `json:"expiresInMinutes"`
+	Followers        bool `json:"followers"`
+}
+
+// shareExecution starts sharing the caller's execution and replies with the token, shown only this once
+func shareExecution(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		objID, ok := executionID(w, r)
+		if !ok {
+			return
+		}
+
+		var req shareExecutionRequest
+		// an empty body shares with the token alone for the default time
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ExpiresInMinutes < 0 {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		grant, err := execs.Share(ctx, objID, a.UserID, time.Duration(req.ExpiresInMinutes)*time.Minute, req.Followers)
+		if err != nil {
+			writeRepoError(w, err, "share execution")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(grant)
+	}
+}
+
+func unshareExecution(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		objID, ok := executionID(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := execs.Unshare(ctx, objID, a.UserID); err != nil {
+			writeRepoError(w, err, "unshare execution")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// viewSharedExecution shows the execution a share token opens to anyone holding it
+func viewSharedExecution(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		view, err := execs.ViewShared(ctx, mux.Vars(r)["token"])
+		if err != nil {
+			writeRepoError(w, err, "view shared execution")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(view)
+	}
+}
+
+// viewExecutionAsFollower shows an execution shared with the tourist's followers to one of them
+func viewExecutionAsFollower(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		objID, ok := executionID(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		view, err := execs.ViewAsFollower(ctx, objID, a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "view shared execution")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(view)
+	}
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"tour-service/auth"
+	"tour-service/model"
+	"tour-service/repository"
+	"tour-service/service"
+
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	wsMaxMessage = 1024
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// the handshake is authenticated by bearer token rather than cookies, so any origin may connect
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// streamExecution upgrades to a WebSocket on which the tourist sends positions as
+// {"latitude": .., "longitude": ..} messages and receives execution events as they happen,
+// including those caused by positions posted to /executions/{execId}/location
+func streamExecution(execs *service.ExecutionService, hub *executionHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		execID, ok := executionID(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		_, err := execs.GetTracked(ctx, execID, a.UserID)
+		cancel()
+		if err != nil {
+			writeRepoError(w, err, "get execution")
+			return
+		}
+
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has already replied with an error status
+			return
+		}
+		defer conn.Close()
+
+		events := hub.subscribe(execID)
+		defer hub.unsubscribe(execID, events)
+
+		replies := make(chan model.ExecutionEvent, 8)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			readPositions(r.Context(), conn, execs, hub, execID, a.UserID, replies)
+		}()
+
+		ping := time.NewTicker(wsPingPeriod)
+		defer ping.Stop()
+		for {
+			var ev model.ExecutionEvent
+			select {
+			case <-done:
+				// the reader has exited, so flush the errors it left to tell the tourist why
+				for len(replies) > 0 {
+					conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+					conn.WriteJSON(<-replies)
+				}
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(wsWriteWait))
+				return
+			case ev = <-events:
+			case ev = <-replies:
+			case <-ping.C:
+				conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readPositions tracks every position received on the connection until it closes or the execution
+// stops being active. Events are broadcast through the hub; errors go back to this connection only.
+func readPositions(ctx context.Context, conn *websocket.Conn, execs *service.ExecutionService, hub *executionHub,
+	execID primitive.ObjectID, userId string, replies chan<- model.ExecutionEvent) {
+	reply := func(msg string) {
+		select {
+		case replies <- model.ExecutionEvent{Type: model.EventError, ExecutionID: execID, Message: msg, Timestamp: time.Now().UTC()}:
+		default:
+		}
+	}
+
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		var req addLocationRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			reply("invalid location")
+			continue
+		}
+
+		msgCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		evs, err := execs.RecordLocation(msgCtx, execID, userId, req.Latitude, req.Longitude)
+		cancel()
+		hub.publish(execID, evs...)
+		switch {
+		case errors.Is(err, service.ErrInvalidCoordinates):
+			reply(err.Error())
+		case errors.Is(err, repository.ErrExecutionNotActive), errors.Is(err, repository.ErrExecutionNotFound),
+			errors.Is(err, repository.ErrForbidden):
+			reply(err.Error())
+			return
+		case err != nil:
+			log.Println("record location error:", err)
+			reply("failed to record location")
+		}
+	}
+}
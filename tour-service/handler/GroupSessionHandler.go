@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"tour-service/auth"
+	"tour-service/model"
+	"tour-service/service"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// groupID parses the {groupId} route variable, replying 400 when it is malformed
+func groupID(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	objID, err := primitive.ObjectIDFromHex(mux.Vars(r)["groupId"])
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return primitive.NilObjectID, false
+	}
+	return objID, true
+}
+
+type createGroupRequest struct {
+	TourID     string `json:"tourId"`
+	MaxMembers int    `json:"maxMembers"` // optional, service.DefaultGroupSize when left out
+}
+
+// createGroup opens a guided session of the caller's tour and replies with its join code
+func createGroup(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req createGroupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if _, err := primitive.ObjectIDFromHex(req.TourID); err != nil {
+			http.Error(w, "invalid tourId", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		g, err := execs.StartGroup(ctx, a.UserID, req.TourID, req.MaxMembers)
+		if err != nil {
+			writeRepoError(w, err, "create group")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(g)
+	}
+}
+
+type joinGroupRequest struct {
+	JoinCode      string              `json:"joinCode"`
+	TransportMode model.TransportMode `json:"transportMode"`
+}
+
+// joinGroup starts the caller's execution as a member of the session with the given code
+func joinGroup(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req joinGroupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.JoinCode == "" {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		exec, err := execs.JoinGroup(ctx, a.UserID, req.JoinCode, req.TransportMode)
+		if err != nil {
+			writeRepoError(w, err, "join group")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(exec)
+	}
+}
+
+// getGroup is the guide's live view of the session's members
+func getGroup(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		objID, ok := groupID(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		view, err := execs.GetGroup(ctx, objID, a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "get group")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(view)
+	}
+}
+
+// completeGroupPoint marks a key point reached for the whole group; every member following their
+// execution over a WebSocket is told as well
+func completeGroupPoint(execs *service.ExecutionService, hub *executionHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		objID, ok := groupID(w, r)
+		if !ok {
+			return
+		}
+
+		var req completePointRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		kpID, err := primitive.ObjectIDFromHex(req.KeyPointID)
+		if err != nil {
+			http.Error(w, "invalid keyPointId", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		events, err := execs.CompleteGroupPoint(ctx, objID, a.UserID, kpID)
+		for _, ev := range events {
+			hub.publish(ev.ExecutionID, ev)
+		}
+		if err != nil {
+			writeRepoError(w, err, "complete group point")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+	}
+}
+
+func closeGroup(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		objID, ok := groupID(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := execs.CloseGroup(ctx, objID, a.UserID); err != nil {
+			writeRepoError(w, err, "close group")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
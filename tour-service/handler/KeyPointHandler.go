@@ -3,12 +3,15 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
 	"tour-service/auth"
 	"tour-service/model"
+	"tour-service/repository"
+	"tour-service/utils"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -17,11 +20,30 @@ import (
 type kpRepo interface {
 	CreateKeyPoint(ctx context.Context, kp *model.KeyPoint) (*model.KeyPoint, error)
 	GetKeyPointsByTour(ctx context.Context, tourId primitive.ObjectID) ([]model.KeyPoint, error)
-	UpdateKeyPoint(ctx context.Context, keypointId string, updates map[string]interface{}) (*model.KeyPoint, error)
-	DeleteKeyPoint(ctx context.Context, keypointId string) error
+	GetKeyPointByID(ctx context.Context, keypointId string) (*model.KeyPoint, error)
+	UpdateKeyPoint(ctx context.Context, tourId primitive.ObjectID, keypointId string, upd model.KeyPointUpdate) (*model.KeyPoint, error)
+	DeleteKeyPoint(ctx context.Context, tourId primitive.ObjectID, keypointId string) error
 	UpdateKeyPointsOrder(ctx context.Context, tourId primitive.ObjectID, orderedIds []string) error
 	GetTourByID(ctx context.Context, tourId string) (*model.Tour, error)
 	HasUserPurchasedTour(ctx context.Context, userId string, tourId string) (bool, error)
+	RecalculateRoute(ctx context.Context, tourId primitive.ObjectID) (*model.Tour, error)
+	GetTourVersion(ctx context.Context, tourId primitive.ObjectID, version int) (*model.TourVersion, error)
+}
+
+type routeCalculator interface {
+	RecalculateRoute(ctx context.Context, tourId primitive.ObjectID) (*model.Tour, error)
+}
+
+// recalculateRoute refreshes the distance and durations of a draft tour after its key points changed.
+// Published and archived tours keep their route until the revision staging the change is published.
+// The key point change itself already succeeded, so a failure here is only logged.
+func recalculateRoute(ctx context.Context, repo routeCalculator, tour *model.Tour) {
+	if tour.Status != model.TourDraft {
+		return
+	}
+	if _, err := repo.RecalculateRoute(ctx, tour.ID); err != nil {
+		log.Println("recalculate route error:", err)
+	}
 }
 
 func RegisterKeyPointRoutes(public *mux.Router, authRouter *mux.Router, repo kpRepo) {
@@ -42,10 +64,18 @@ type createKeyPointRequest struct {
 	ImageURL    string  `json:"imageUrl,omitempty"`
 	Latitude    float64 `json:"latitude"`
 	Longitude   float64 `json:"longitude"`
+	// Radius in meters and Geofence polygon decide when a tourist counts as at the key point
+	Radius   float64           `json:"radius,omitempty"`
+	Geofence *model.GeoPolygon `json:"geofence,omitempty"`
 }
 
 func createKeyPoint(repo kpRepo) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
 		vars := mux.Vars(r)
 		tourIdStr := vars["tourId"]
 		if tourIdStr == "" {
@@ -66,6 +96,14 @@ func createKeyPoint(repo kpRepo) http.HandlerFunc {
 			http.Error(w, "name required", http.StatusBadRequest)
 			return
 		}
+		if !utils.ValidCoordinates(req.Latitude, req.Longitude) {
+			http.Error(w, "coordinates out of range", http.StatusBadRequest)
+			return
+		}
+		if err := utils.CheckKeyPointArea(req.Radius, req.Geofence); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		kp := &model.KeyPoint{
 			TourID:      tourID,
 			Name:        req.Name,
@@ -73,15 +111,22 @@ func createKeyPoint(repo kpRepo) http.HandlerFunc {
 			ImageURL:    req.ImageURL,
 			Latitude:    req.Latitude,
 			Longitude:   req.Longitude,
+			Radius:      req.Radius,
+			Geofence:    req.Geofence,
 		}
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
+		tour, err := authorizeTourAuthor(ctx, repo, tourIdStr, a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "get tour")
+			return
+		}
 		created, err := repo.CreateKeyPoint(ctx, kp)
 		if err != nil {
-			log.Println("create keypoint error:", err)
-			http.Error(w, "failed to create keypoint", http.StatusInternalServerError)
+			writeRepoError(w, err, "create keypoint")
 			return
 		}
+		recalculateRoute(ctx, repo, tour)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(created)
@@ -96,8 +141,7 @@ func listKeyPoints(repo kpRepo) http.HandlerFunc {
 			http.Error(w, "tourId required", http.StatusBadRequest)
 			return
 		}
-		tourID, err := primitive.ObjectIDFromHex(tourIdStr)
-		if err != nil {
+		if _, err := primitive.ObjectIDFromHex(tourIdStr); err != nil {
 			http.Error(w, "invalid tourId", http.StatusBadRequest)
 			return
 		}
@@ -107,51 +151,96 @@ func listKeyPoints(repo kpRepo) http.HandlerFunc {
 		// Get the tour to check status and author
 		tour, err := repo.GetTourByID(ctx, tourIdStr)
 		if err != nil {
-			log.Println("get tour error:", err)
-			http.Error(w, "failed to get tour", http.StatusInternalServerError)
+			writeRepoError(w, err, "get tour")
 			return
 		}
 
-		kps, err := repo.GetKeyPointsByTour(ctx, tourID)
+		// Get user from JWT (optional - middleware sets it if token is present)
+		authCtx := auth.GetAuth(r)
+		kps, err := tourKeyPoints(ctx, repo, authCtx, tour)
 		if err != nil {
 			log.Println("list keypoints error:", err)
 			http.Error(w, "failed to list keypoints", http.StatusInternalServerError)
 			return
 		}
-
-		// Check if we should restrict keypoints for tourists
-		// Get user from JWT (optional - middleware sets it if token is present)
-		authCtx := auth.GetAuth(r)
-
-		// If tour is published and user is not the author, check if they purchased the tour
-		if tour.Status == "published" {
-			if authCtx == nil || authCtx.UserID != tour.AuthorID {
-				// User is not the author - check if they purchased the tour
-				hasPurchased := false
-				if authCtx != nil {
-					// Check if user has purchased this tour
-					purchased, err := repo.HasUserPurchasedTour(ctx, authCtx.UserID, tourIdStr)
-					if err != nil {
-						log.Println("error checking purchase status:", err)
-					} else {
-						hasPurchased = purchased
-					}
-				}
-
-				// If user hasn't purchased, return only first keypoint
-				if !hasPurchased && len(kps) > 0 {
-					kps = kps[:1] // Return only first keypoint
-				}
-			}
-		}
+		kps = visibleKeyPoints(ctx, repo, authCtx, tour, kps)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(kps)
 	}
 }
 
+type keyPointLister interface {
+	GetKeyPointsByTour(ctx context.Context, tourId primitive.ObjectID) ([]model.KeyPoint, error)
+	GetTourVersion(ctx context.Context, tourId primitive.ObjectID, version int) (*model.TourVersion, error)
+}
+
+type liveKeyPoints interface {
+	GetKeyPointsByTour(ctx context.Context, tourId primitive.ObjectID) ([]model.KeyPoint, error)
+}
+
+// editedKeyPoints returns the key points the author works on: those staged in the pending revision
+// of a published tour, otherwise the live ones
+func editedKeyPoints(ctx context.Context, repo liveKeyPoints, tour *model.Tour) ([]model.KeyPoint, error) {
+	if rev := tour.PendingRevision; rev != nil && rev.KeyPointsChanged {
+		return rev.KeyPoints, nil
+	}
+	return repo.GetKeyPointsByTour(ctx, tour.ID)
+}
+
+// tourKeyPoints returns the key points a viewer works with: the author edits their own,
+// everyone else sees those of the published version
+func tourKeyPoints(ctx context.Context, repo keyPointLister, authCtx *auth.AuthContext, tour *model.Tour) ([]model.KeyPoint, error) {
+	if authCtx != nil && authCtx.UserID == tour.AuthorID {
+		return editedKeyPoints(ctx, repo, tour)
+	}
+	if tour.CurrentVersion == 0 {
+		return repo.GetKeyPointsByTour(ctx, tour.ID)
+	}
+	v, err := repo.GetTourVersion(ctx, tour.ID, tour.CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
+	return v.KeyPoints, nil
+}
+
+type purchaseChecker interface {
+	HasUserPurchasedTour(ctx context.Context, userId string, tourId string) (bool, error)
+}
+
+// visibleKeyPoints restricts key points for tourists: on a published tour, anyone other than
+// the author who has not purchased it only gets the first key point.
+func visibleKeyPoints(ctx context.Context, repo purchaseChecker, authCtx *auth.AuthContext, tour *model.Tour, kps []model.KeyPoint) []model.KeyPoint {
+	if tour.Status != model.TourPublished {
+		return kps
+	}
+	if authCtx != nil && authCtx.UserID == tour.AuthorID {
+		return kps
+	}
+	// User is not the author - check if they purchased the tour
+	hasPurchased := false
+	if authCtx != nil {
+		purchased, err := repo.HasUserPurchasedTour(ctx, authCtx.UserID, tour.ID.Hex())
+		if err != nil {
+			log.Println("error checking purchase status:", err)
+		} else {
+			hasPurchased = purchased
+		}
+	}
+	// If user hasn't purchased, return only first keypoint
+	if !hasPurchased && len(kps) > 0 {
+		return kps[:1]
+	}
+	return kps
+}
+
 func updateKeyPoint(repo kpRepo) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
 		vars := mux.Vars(r)
 		keypointId := vars["keypointId"]
 		if keypointId == "" {
@@ -159,22 +248,57 @@ func updateKeyPoint(repo kpRepo) http.HandlerFunc {
 			return
 		}
 
-		var updates map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		// unknown fields (tourId, id, createdAt, ...) are rejected rather than silently applied
+		var upd model.KeyPointUpdate
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&upd); err != nil {
 			http.Error(w, "invalid body", http.StatusBadRequest)
 			return
 		}
+		if upd.Name != nil && *upd.Name == "" {
+			http.Error(w, "name cannot be empty", http.StatusBadRequest)
+			return
+		}
+		if (upd.Latitude != nil && !utils.ValidCoordinates(*upd.Latitude, 0)) ||
+			(upd.Longitude != nil && !utils.ValidCoordinates(0, *upd.Longitude)) {
+			http.Error(w, "coordinates out of range", http.StatusBadRequest)
+			return
+		}
+		if upd.Radius != nil {
+			if err := utils.CheckKeyPointArea(*upd.Radius, nil); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if upd.Geofence != nil && len(upd.Geofence.Coordinates) > 0 {
+			if err := utils.CheckKeyPointArea(0, upd.Geofence); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		updated, err := repo.UpdateKeyPoint(ctx, keypointId, updates)
+		kp, err := repo.GetKeyPointByID(ctx, keypointId)
+		if err != nil {
+			writeRepoError(w, err, "get keypoint")
+			return
+		}
+		tour, err := authorizeTourAuthor(ctx, repo, kp.TourID.Hex(), a.UserID)
 		if err != nil {
-			log.Println("update keypoint error:", err)
-			http.Error(w, "failed to update keypoint", http.StatusInternalServerError)
+			writeRepoError(w, err, "get tour")
 			return
 		}
 
+		updated, err := repo.UpdateKeyPoint(ctx, kp.TourID, keypointId, upd)
+		if err != nil {
+			writeRepoError(w, err, "update keypoint")
+			return
+		}
+		recalculateRoute(ctx, repo, tour)
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(updated)
 	}
@@ -182,6 +306,11 @@ func updateKeyPoint(repo kpRepo) http.HandlerFunc {
 
 func deleteKeyPoint(repo kpRepo) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
 		vars := mux.Vars(r)
 		keypointId := vars["keypointId"]
 		if keypointId == "" {
@@ -192,12 +321,22 @@ func deleteKeyPoint(repo kpRepo) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		err := repo.DeleteKeyPoint(ctx, keypointId)
+		kp, err := repo.GetKeyPointByID(ctx, keypointId)
 		if err != nil {
-			log.Println("delete keypoint error:", err)
-			http.Error(w, "failed to delete keypoint", http.StatusInternalServerError)
+			writeRepoError(w, err, "get keypoint")
+			return
+		}
+		tour, err := authorizeTourAuthor(ctx, repo, kp.TourID.Hex(), a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "get tour")
+			return
+		}
+
+		if err := repo.DeleteKeyPoint(ctx, kp.TourID, keypointId); err != nil {
+			writeRepoError(w, err, "delete keypoint")
 			return
 		}
+		recalculateRoute(ctx, repo, tour)
 
 		w.WriteHeader(http.StatusNoContent)
 	}
@@ -209,6 +348,11 @@ type reorderRequest struct {
 
 func reorderKeyPoints(repo kpRepo) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
 		vars := mux.Vars(r)
 		tourIdStr := vars["tourId"]
 		if tourIdStr == "" {
@@ -235,12 +379,22 @@ func reorderKeyPoints(repo kpRepo) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
+		tour, err := authorizeTourAuthor(ctx, repo, tourIdStr, a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "get tour")
+			return
+		}
+
 		err = repo.UpdateKeyPointsOrder(ctx, tourID, req.KeyPointIds)
+		if errors.Is(err, repository.ErrKeyPointNotFound) {
+			http.Error(w, "keypointIds must be distinct keypoints of this tour", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
-			log.Println("reorder keypoints error:", err)
-			http.Error(w, "failed to reorder keypoints", http.StatusInternalServerError)
+			writeRepoError(w, err, "reorder keypoints")
 			return
 		}
+		recalculateRoute(ctx, repo, tour)
 
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"message": "keypoints reordered successfully"})
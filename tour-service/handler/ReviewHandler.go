@@ -3,12 +3,16 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"tour-service/auth"
 	"tour-service/model"
+	"tour-service/repository"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -16,24 +20,94 @@ import (
 
 type reviewRepo interface {
 	CreateReview(ctx context.Context, rev *model.Review) (*model.Review, error)
-	GetReviewsByTour(ctx context.Context, tourId primitive.ObjectID) ([]model.Review, error)
+	GetReviewsByTour(ctx context.Context, tourId primitive.ObjectID, q model.ReviewQuery) ([]model.Review, int64, error)
+	UpdateReview(ctx context.Context, reviewId string, authorId string, upd model.ReviewUpdate) (*model.Review, error)
+	DeleteReview(ctx context.Context, reviewId string, authorId string) error
+	ReplyToReview(ctx context.Context, reviewId string, guideId string, comment string) (*model.Review, error)
+	DeleteReviewReply(ctx context.Context, reviewId string, guideId string) error
+	VoteReview(ctx context.Context, reviewId string, userId string, helpful bool) (*model.Review, error)
+	RemoveReviewVote(ctx context.Context, reviewId string, userId string) error
+	ReportReview(ctx context.Context, reviewId string, report model.ReviewReport) (*model.Review, error)
+	ModerateReview(ctx context.Context, reviewId string, adminId string, hide bool) (*model.Review, error)
+	GetModerationQueue(ctx context.Context, limit int) ([]model.ModerationItem, error)
 	HasUserReviewedTour(ctx context.Context, tourId primitive.ObjectID, authorId string) (bool, error)
+	GetTourRatingStats(ctx context.Context, tourId primitive.ObjectID) (*model.RatingSummary, error)
+	GetReviewExecution(ctx context.Context, touristId string, tourId primitive.ObjectID) (*model.TourExecution, error)
+	GetExecutionKeyPoints(ctx context.Context, exec *model.TourExecution) ([]model.KeyPoint, error)
+	HasUserPurchasedTour(ctx context.Context, userId string, tourId string) (bool, error)
 }
 
+// minReviewProgress is the share of key points an execution must have reached to count as a visit
+const minReviewProgress = 0.5
+
 func RegisterReviewRoutes(public *mux.Router, authRouter *mux.Router, repo reviewRepo) {
 	// protected routes
 	if authRouter != nil {
 		authRouter.HandleFunc("/tours/{tourId}/reviews", createReview(repo)).Methods("POST")
+		authRouter.HandleFunc("/reviews/reports", moderationQueue(repo)).Methods("GET")
+		authRouter.HandleFunc("/reviews/{reviewId}/report", reportReview(repo)).Methods("POST")
+		authRouter.HandleFunc("/reviews/{reviewId}/hide", moderateReview(repo, true)).Methods("POST")
+		authRouter.HandleFunc("/reviews/{reviewId}/restore", moderateReview(repo, false)).Methods("POST")
+		authRouter.HandleFunc("/reviews/{reviewId}", updateReview(repo)).Methods("PUT")
+		authRouter.HandleFunc("/reviews/{reviewId}", deleteReview(repo)).Methods("DELETE")
+		authRouter.HandleFunc("/reviews/{reviewId}/reply", replyToReview(repo)).Methods("PUT")
+		authRouter.HandleFunc("/reviews/{reviewId}/reply", deleteReviewReply(repo)).Methods("DELETE")
+		authRouter.HandleFunc("/reviews/{reviewId}/vote", voteReview(repo)).Methods("PUT")
+		authRouter.HandleFunc("/reviews/{reviewId}/vote", removeReviewVote(repo)).Methods("DELETE")
 	}
 	// public routes
 	public.HandleFunc("/tours/{tourId}/reviews", listReviews(repo)).Methods("GET")
+	public.HandleFunc("/tours/{tourId}/reviews/stats", reviewStats(repo)).Methods("GET")
 }
 
 type createReviewRequest struct {
 	Rating    int        `json:"rating"`
 	Comment   string     `json:"comment,omitempty"`
 	Images    []string   `json:"images,omitempty"`
-	VisitedAt *time.Time `json:"visitedAt,omitempty"`
+	VisitedAt *time.Time `json:"visitedAt,omitempty"` // only used for unverified reviews backed by a purchase
+}
+
+// executionProgress returns the share of the execution's key points the tourist has reached
+func executionProgress(ctx context.Context, repo reviewRepo, exec *model.TourExecution) (float64, error) {
+	kps, err := repo.GetExecutionKeyPoints(ctx, exec)
+	if err != nil || len(kps) == 0 {
+		return 0, err
+	}
+	reached := make(map[primitive.ObjectID]bool, len(exec.CompletedPoints))
+	for _, cp := range exec.CompletedPoints {
+		reached[cp.KeyPointID] = true
+	}
+	n := 0
+	for _, kp := range kps {
+		if reached[kp.ID] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(kps)), nil
+}
+
+// reviewProof finds what entitles the user to review the tour. An execution that reached
+// minReviewProgress of the key points makes a verified review, whatever status the tourist gave it;
+// a purchase alone is enough for an unverified one. ok is false when the user has neither.
+func reviewProof(ctx context.Context, repo reviewRepo, userId string, tourID primitive.ObjectID) (exec *model.TourExecution, ok bool, err error) {
+	exec, err = repo.GetReviewExecution(ctx, userId, tourID)
+	if err != nil {
+		return nil, false, err
+	}
+	if exec != nil {
+		progress, err := executionProgress(ctx, repo, exec)
+		if err != nil {
+			return nil, false, err
+		}
+		if progress >= minReviewProgress {
+			return exec, true, nil
+		}
+	}
+	purchased, err := repo.HasUserPurchasedTour(ctx, userId, tourID.Hex())
+	if err != nil {
+		return nil, false, err
+	}
+	return nil, purchased, nil
 }
 
 func createReview(repo reviewRepo) http.HandlerFunc {
@@ -81,6 +155,18 @@ func createReview(repo reviewRepo) http.HandlerFunc {
 			http.Error(w, "rating(1-5) required", http.StatusBadRequest)
 			return
 		}
+
+		exec, ok, err := reviewProof(ctx, repo, a.UserID, tourID)
+		if err != nil {
+			log.Println("error checking review eligibility:", err)
+			http.Error(w, "failed to check review eligibility", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "only tourists who took or purchased this tour can review it", http.StatusForbidden)
+			return
+		}
+
 		rev := &model.Review{
 			TourID:     tourID,
 			AuthorID:   a.UserID,
@@ -91,11 +177,20 @@ func createReview(repo reviewRepo) http.HandlerFunc {
 			VisitedAt:  req.VisitedAt,
 			CreatedAt:  time.Now().UTC(),
 		}
+		if exec != nil {
+			// the visit date comes from the execution, not from the client
+			visitedAt := exec.LastActivity
+			if exec.FinishedAt != nil {
+				visitedAt = *exec.FinishedAt
+			}
+			rev.ExecutionID = &exec.ID
+			rev.Verified = true
+			rev.VisitedAt = &visitedAt
+		}
 
 		created, err := repo.CreateReview(ctx, rev)
 		if err != nil {
-			log.Println("create review error:", err)
-			http.Error(w, "failed to create review", http.StatusInternalServerError)
+			writeRepoError(w, err, "create review")
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -117,15 +212,330 @@ func listReviews(repo reviewRepo) http.HandlerFunc {
 			http.Error(w, "invalid tourId", http.StatusBadRequest)
 			return
 		}
+		q := r.URL.Query()
+		query := model.ReviewQuery{Sort: q.Get("sort")}
+		switch query.Sort {
+		case "", model.ReviewSortNewest, model.ReviewSortHelpful, model.ReviewSortRating:
+		default:
+			http.Error(w, "sort must be newest, helpful or rating", http.StatusBadRequest)
+			return
+		}
+		page, err := intParam(q, "page")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if page != nil {
+			query.Page = *page
+		}
+		limit, err := intParam(q, "limit")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if limit != nil {
+			query.Limit = *limit
+		}
+
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
-		revs, err := repo.GetReviewsByTour(ctx, tourID)
+		revs, total, err := repo.GetReviewsByTour(ctx, tourID, query)
 		if err != nil {
 			log.Println("list reviews error:", err)
 			http.Error(w, "failed to list reviews", http.StatusInternalServerError)
 			return
 		}
+		// the body stays a plain array for existing clients; the total goes in a header
+		w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(revs)
 	}
 }
+
+// reviewStats returns the rating aggregate of a tour: average, count and the 1-5 star histogram
+func reviewStats(repo reviewRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tourID, err := primitive.ObjectIDFromHex(mux.Vars(r)["tourId"])
+		if err != nil {
+			http.Error(w, "invalid tourId", http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		stats, err := repo.GetTourRatingStats(ctx, tourID)
+		if err != nil {
+			writeRepoError(w, err, "get review stats")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	}
+}
+
+// updateReview lets the author change the rating, comment or images of their review
+func updateReview(repo reviewRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var upd model.ReviewUpdate
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&upd); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		if upd.Rating != nil && (*upd.Rating < 1 || *upd.Rating > 5) {
+			http.Error(w, "rating must be between 1 and 5", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		updated, err := repo.UpdateReview(ctx, mux.Vars(r)["reviewId"], a.UserID, upd)
+		if err != nil {
+			writeRepoError(w, err, "update review")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+	}
+}
+
+func deleteReview(repo reviewRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := repo.DeleteReview(ctx, mux.Vars(r)["reviewId"], a.UserID); err != nil {
+			writeRepoError(w, err, "delete review")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type replyRequest struct {
+	Comment string `json:"comment"`
+}
+
+// replyToReview posts or replaces the guide's public reply; only the tour's author may reply
+func replyToReview(repo reviewRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req replyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Comment) == "" {
+			http.Error(w, "comment required", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		updated, err := repo.ReplyToReview(ctx, mux.Vars(r)["reviewId"], a.UserID, req.Comment)
+		if err != nil {
+			writeRepoError(w, err, "reply to review")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+	}
+}
+
+func deleteReviewReply(repo reviewRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := repo.DeleteReviewReply(ctx, mux.Vars(r)["reviewId"], a.UserID); err != nil {
+			writeRepoError(w, err, "delete reply")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type voteRequest struct {
+	Helpful *bool `json:"helpful"`
+}
+
+// voteReview records the caller's helpful/unhelpful vote; voting again changes it
+func voteReview(repo reviewRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req voteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Helpful == nil {
+			http.Error(w, "helpful (true/false) required", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		updated, err := repo.VoteReview(ctx, mux.Vars(r)["reviewId"], a.UserID, *req.Helpful)
+		if err != nil {
+			writeRepoError(w, err, "vote on review")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+	}
+}
+
+func removeReviewVote(repo reviewRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := repo.RemoveReviewVote(ctx, mux.Vars(r)["reviewId"], a.UserID); err != nil {
+			writeRepoError(w, err, "remove vote")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type reportRequest struct {
+	Reason  string `json:"reason"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// reportReview flags abusive review text or images for the admins
+func reportReview(repo reviewRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req reportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		switch req.Reason {
+		case model.ReportSpam, model.ReportOffensive, model.ReportInappropriate, model.ReportOther:
+		default:
+			http.Error(w, "reason must be spam, offensive, inappropriate_image or other", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		_, err := repo.ReportReview(ctx, mux.Vars(r)["reviewId"], model.ReviewReport{
+			ReporterID: a.UserID,
+			Reason:     req.Reason,
+			Comment:    req.Comment,
+		})
+		if errors.Is(err, repository.ErrAlreadyReported) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			writeRepoError(w, err, "report review")
+			return
+		}
+		// the reporter doesn't learn whether the review got hidden
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// moderationQueue lists reported reviews with their open reports; admins only
+func moderationQueue(repo reviewRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !a.HasRole("admin") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		limit, err := intParam(r.URL.Query(), "limit")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n := 0
+		if limit != nil {
+			n = *limit
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		items, err := repo.GetModerationQueue(ctx, n)
+		if err != nil {
+			writeRepoError(w, err, "list reported reviews")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+	}
+}
+
+// moderateReview hides or restores a review and resolves its open reports; admins only
+func moderateReview(repo reviewRepo, hide bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !a.HasRole("admin") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		updated, err := repo.ModerateReview(ctx, mux.Vars(r)["reviewId"], a.UserID, hide)
+		if err != nil {
+			writeRepoError(w, err, "moderate review")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(model.NewAdminReview(*updated))
+	}
+}
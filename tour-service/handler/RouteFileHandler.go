@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"tour-service/auth"
+	"tour-service/model"
+	"tour-service/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type routeFileRepo interface {
+	GetTourByID(ctx context.Context, tourId string) (*model.Tour, error)
+	GetKeyPointsByTour(ctx context.Context, tourId primitive.ObjectID) ([]model.KeyPoint, error)
+	CreateKeyPoints(ctx context.Context, tourId primitive.ObjectID, kps []model.KeyPoint) ([]model.KeyPoint, error)
+	HasUserPurchasedTour(ctx context.Context, userId string, tourId string) (bool, error)
+	RecalculateRoute(ctx context.Context, tourId primitive.ObjectID) (*model.Tour, error)
+	GetTourVersion(ctx context.Context, tourId primitive.ObjectID, version int) (*model.TourVersion, error)
+}
+
+func RegisterRouteFileRoutes(public *mux.Router, authRouter *mux.Router, repo routeFileRepo) {
+	// protected routes
+	if authRouter != nil {
+		authRouter.HandleFunc("/tours/{id}/import", importKeyPoints(repo)).Methods("POST")
+	}
+	// public routes
+	public.HandleFunc("/tours/{id}/export", exportTour(repo)).Methods("GET")
+}
+
+const (
+	formatGPX     = "gpx"
+	formatKML     = "kml"
+	formatGeoJSON = "geojson"
+
+	maxRouteFileSize = 5 << 20
+)
+
+var routeFileContentTypes = map[string]string{
+	formatGPX:     "application/gpx+xml",
+	formatKML:     "application/vnd.google-earth.kml+xml",
+	formatGeoJSON: "application/geo+json",
+}
+
+// detectRouteFormat picks the import format from ?format=, then the Content-Type, then the body itself
+func detectRouteFormat(r *http.Request, body []byte) string {
+	if f := strings.ToLower(r.URL.Query().Get("format")); f != "" {
+		return f
+	}
+	ct := r.Header.Get("Content-Type")
+	for format, mime := range routeFileContentTypes {
+		if strings.HasPrefix(ct, mime) {
+			return format
+		}
+	}
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return formatGeoJSON
+	case bytes.Contains(trimmed, []byte("<gpx")):
+		return formatGPX
+	case bytes.Contains(trimmed, []byte("<kml")):
+		return formatKML
+	}
+	return ""
+}
+
+// importKeyPoints creates key points in bulk from an uploaded GPX, KML or GeoJSON file,
+// appending them after the tour's existing key points in file order
+func importKeyPoints(repo routeFileRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		vars := mux.Vars(r)
+		tourIdStr := vars["id"]
+		tourID, err := primitive.ObjectIDFromHex(tourIdStr)
+		if err != nil {
+			http.Error(w, "invalid tour id", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRouteFileSize))
+		if err != nil {
+			http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		var kps []model.KeyPoint
+		switch detectRouteFormat(r, body) {
+		case formatGPX:
+			kps, err = utils.ParseGPX(bytes.NewReader(body))
+		case formatKML:
+			kps, err = utils.ParseKML(bytes.NewReader(body))
+		case formatGeoJSON:
+			kps, err = utils.ParseGeoJSON(bytes.NewReader(body))
+		default:
+			http.Error(w, "unsupported format, use gpx, kml or geojson", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		tour, err := authorizeTourAuthor(ctx, repo, tourIdStr, a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "get tour")
+			return
+		}
+
+		created, err := repo.CreateKeyPoints(ctx, tourID, kps)
+		if err != nil {
+			writeRepoError(w, err, "import keypoints")
+			return
+		}
+		recalculateRoute(ctx, repo, tour)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// exportTour serializes a tour and the key points the caller is allowed to see, e.g.
+// GET /tours/{id}/export?format=gpx. The author exports what they are editing; everyone else
+// only the published version.
+func exportTour(repo routeFileRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		tourIdStr := vars["id"]
+		if _, err := primitive.ObjectIDFromHex(tourIdStr); err != nil {
+			http.Error(w, "invalid tour id", http.StatusBadRequest)
+			return
+		}
+		format := strings.ToLower(r.URL.Query().Get("format"))
+		if format == "" {
+			format = formatGPX
+		}
+		contentType, ok := routeFileContentTypes[format]
+		if !ok {
+			http.Error(w, "unsupported format, use gpx, kml or geojson", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		tour, err := repo.GetTourByID(ctx, tourIdStr)
+		if err != nil {
+			http.Error(w, "tour not found", http.StatusNotFound)
+			return
+		}
+		authCtx := auth.GetAuth(r)
+		isAuthor := authCtx != nil && authCtx.UserID == tour.AuthorID
+		// drafts and archived tours are only exported to their author
+		if !isAuthor && tour.Status != model.TourPublished {
+			http.Error(w, "tour not found", http.StatusNotFound)
+			return
+		}
+		exported := tour
+		var kps []model.KeyPoint
+		if isAuthor || tour.CurrentVersion == 0 {
+			kps, err = tourKeyPoints(ctx, repo, authCtx, tour)
+		} else {
+			// everyone else gets the published version, its fields as well as its key points
+			var v *model.TourVersion
+			if v, err = repo.GetTourVersion(ctx, tour.ID, tour.CurrentVersion); err == nil {
+				exported, kps = &v.Tour, v.KeyPoints
+			}
+		}
+		if err != nil {
+			log.Println("list keypoints error:", err)
+			http.Error(w, "failed to list keypoints", http.StatusInternalServerError)
+			return
+		}
+		kps = visibleKeyPoints(ctx, repo, authCtx, tour, kps)
+
+		var buf bytes.Buffer
+		switch format {
+		case formatGPX:
+			err = utils.EncodeGPX(&buf, exported, kps)
+		case formatKML:
+			err = utils.EncodeKML(&buf, exported, kps)
+		case formatGeoJSON:
+			err = utils.EncodeGeoJSON(&buf, exported, kps)
+		}
+		if err != nil {
+			log.Println("export tour error:", err)
+			http.Error(w, "failed to export tour", http.StatusInternalServerError)
+			return
+		}
+
+		filename := strings.Trim(unsafeFileChars.ReplaceAllString(exported.Name, "_"), "_")
+		if filename == "" {
+			filename = exported.ID.Hex()
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+"."+format+`"`)
+		w.Write(buf.Bytes())
+	}
+}
@@ -1,46 +1,61 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"tour-service/auth"
 	"tour-service/model"
+	"tour-service/service"
 	"tour-service/utils"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type tourExecRepo interface {
-	CreateExecution(ctx context.Context, exec *model.TourExecution) (*model.TourExecution, error)
-	GetActiveExecution(ctx context.Context, touristId string, tourId primitive.ObjectID) (*model.TourExecution, error)
-	UpdateExecution(ctx context.Context, exec *model.TourExecution) error
-	AddLocation(ctx context.Context, execId primitive.ObjectID, loc model.Location) error
-	CompletePoint(ctx context.Context, execId primitive.ObjectID, cp model.CompletedPoint) error
-	GetKeyPointsByTour(ctx context.Context, tourId primitive.ObjectID) ([]model.KeyPoint, error)
-	GetTourByID(ctx context.Context, tourId string) (*model.Tour, error)
-	GetExecutionByID(ctx context.Context, execId primitive.ObjectID) (*model.TourExecution, error)
-}
-
-func RegisterExecutionRoutes(authRouter *mux.Router, execRepo tourExecRepo) {
+func RegisterExecutionRoutes(public *mux.Router, authRouter *mux.Router, execs *service.ExecutionService) {
+	hub := newExecutionHub()
 	if authRouter != nil {
-		authRouter.HandleFunc("/executions", createExecution(execRepo)).Methods("POST")
-		authRouter.HandleFunc("/executions/{tourId}/active", getActiveExecution(execRepo)).Methods("GET")
-		authRouter.HandleFunc("/executions/{execId}", updateExecution(execRepo)).Methods("PUT")
-		authRouter.HandleFunc("/executions/{execId}/location", addLocation(execRepo)).Methods("POST")
-		authRouter.HandleFunc("/executions/{execId}/complete", completePoint(execRepo)).Methods("POST")
+		authRouter.HandleFunc("/executions", createExecution(execs)).Methods("POST")
+		authRouter.HandleFunc("/executions", listExecutions(execs)).Methods("GET")
+		authRouter.HandleFunc("/executions/{tourId}/active", getActiveExecution(execs)).Methods("GET")
+		authRouter.HandleFunc("/executions/{execId}", getExecution(execs)).Methods("GET")
+		authRouter.HandleFunc("/executions/{execId}/track.gpx", exportExecutionGPX(execs)).Methods("GET")
+		authRouter.HandleFunc("/executions/{execId}", updateExecution(execs)).Methods("PUT")
+		authRouter.HandleFunc("/executions/{execId}/pause", pauseExecution(execs)).Methods("POST")
+		authRouter.HandleFunc("/executions/{execId}/resume", resumeExecution(execs)).Methods("POST")
+		authRouter.HandleFunc("/executions/{execId}/location", addLocation(execs, hub)).Methods("POST")
+		authRouter.HandleFunc("/executions/{execId}/locations:batch", addLocationBatch(execs, hub)).Methods("POST")
+		authRouter.HandleFunc("/executions/{execId}/ws", streamExecution(execs, hub)).Methods("GET")
+		authRouter.HandleFunc("/executions/{execId}/complete", completePoint(execs, hub)).Methods("POST")
+		authRouter.HandleFunc("/tours/{id}/executions/suspicious", listSuspiciousExecutions(execs)).Methods("GET")
+		authRouter.HandleFunc("/executions/{execId}/share", shareExecution(execs)).Methods("POST")
+		authRouter.HandleFunc("/executions/{execId}/share", unshareExecution(execs)).Methods("DELETE")
+		authRouter.HandleFunc("/shared/executions/{execId}", viewExecutionAsFollower(execs)).Methods("GET")
+
+		// group sessions share the hub so members hear of key points their guide completes
+		authRouter.HandleFunc("/groups", createGroup(execs)).Methods("POST")
+		authRouter.HandleFunc("/groups/join", joinGroup(execs)).Methods("POST")
+		authRouter.HandleFunc("/groups/{groupId}", getGroup(execs)).Methods("GET")
+		authRouter.HandleFunc("/groups/{groupId}/complete", completeGroupPoint(execs, hub)).Methods("POST")
+		authRouter.HandleFunc("/groups/{groupId}/close", closeGroup(execs)).Methods("POST")
 	}
+	// public routes
+	public.HandleFunc("/shared/{token}", viewSharedExecution(execs)).Methods("GET")
 }
 
 type createExecutionRequest struct {
-	TourID string `json:"tourId"`
+	TourID        string              `json:"tourId"`
+	TransportMode model.TransportMode `json:"transportMode"`
+	Preview       bool                `json:"preview"`
 }
 
-func createExecution(repo tourExecRepo) http.HandlerFunc {
+func createExecution(execs *service.ExecutionService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		a := auth.GetAuth(r)
 		if a == nil || a.UserID == "" {
@@ -54,8 +69,7 @@ func createExecution(repo tourExecRepo) http.HandlerFunc {
 			return
 		}
 
-		tourObjID, err := primitive.ObjectIDFromHex(req.TourID)
-		if err != nil {
+		if _, err := primitive.ObjectIDFromHex(req.TourID); err != nil {
 			http.Error(w, "invalid tourId", http.StatusBadRequest)
 			return
 		}
@@ -63,28 +77,9 @@ func createExecution(repo tourExecRepo) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		tour, err := repo.GetTourByID(r.Context(), req.TourID)
-		if err != nil {
-			http.Error(w, "tour not found", http.StatusNotFound)
-			return
-		}
-
-		if tour.Status == "draft" {
-			http.Error(w, "tour is not in draft status", http.StatusBadRequest)
-			return
-		}
-
-		exec := &model.TourExecution{
-			TourID:       tourObjID,
-			TouristID:    a.UserID,
-			Status:       model.ExecutionActive,
-			LastActivity: time.Now().UTC(),
-		}
-
-		created, err := repo.CreateExecution(ctx, exec)
+		created, err := execs.Start(ctx, a.UserID, req.TourID, req.TransportMode, req.Preview)
 		if err != nil {
-			log.Println("create execution error:", err)
-			http.Error(w, "failed to create execution", http.StatusInternalServerError)
+			writeRepoError(w, err, "create execution")
 			return
 		}
 
@@ -94,7 +89,7 @@ func createExecution(repo tourExecRepo) http.HandlerFunc {
 	}
 }
 
-func getActiveExecution(repo tourExecRepo) http.HandlerFunc {
+func getActiveExecution(execs *service.ExecutionService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		a := auth.GetAuth(r)
 		if a == nil || a.UserID == "" {
@@ -118,9 +113,9 @@ func getActiveExecution(repo tourExecRepo) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		exec, err := repo.GetActiveExecution(ctx, a.UserID, tourObjID)
+		exec, err := execs.GetActive(ctx, a.UserID, tourObjID)
 		if err != nil {
-			http.Error(w, "active execution not found", http.StatusNotFound)
+			writeRepoError(w, err, "get active execution")
 			return
 		}
 
@@ -129,12 +124,60 @@ func getActiveExecution(repo tourExecRepo) http.HandlerFunc {
 	}
 }
 
-type updateExecutionRequest struct {
-	Status          string   `json:"status"`
-	CompletedPoints []string `json:"completedPoints"`
+// listExecutions pages through the caller's executions, newest first,
+// e.g. GET /executions?status=completed&tourId=..&page=1&limit=20
+func listExecutions(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		q := r.URL.Query()
+		query := model.ExecutionQuery{Status: model.ExecutionStatus(q.Get("status"))}
+		if tourId := q.Get("tourId"); tourId != "" {
+			objID, err := primitive.ObjectIDFromHex(tourId)
+			if err != nil {
+				http.Error(w, "invalid tourId", http.StatusBadRequest)
+				return
+			}
+			query.TourID = objID
+		}
+		page, err := intParam(q, "page")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if page != nil {
+			query.Page = *page
+		}
+		limit, err := intParam(q, "limit")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if limit != nil {
+			query.Limit = *limit
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		list, total, err := execs.List(ctx, a.UserID, query)
+		if err != nil {
+			writeRepoError(w, err, "list executions")
+			return
+		}
+
+		w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
 }
 
-func updateExecution(repo tourExecRepo) http.HandlerFunc {
+// getExecution returns one of the caller's executions with its track and summary
+func getExecution(execs *service.ExecutionService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		a := auth.GetAuth(r)
 		if a == nil || a.UserID == "" {
@@ -142,16 +185,84 @@ func updateExecution(repo tourExecRepo) http.HandlerFunc {
 			return
 		}
 
-		vars := mux.Vars(r)
-		execId := vars["execId"]
-		if execId == "" {
-			http.Error(w, "execution ID required", http.StatusBadRequest)
+		objID, ok := executionID(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		exec, err := execs.Get(ctx, objID, a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "get execution")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(exec)
+	}
+}
+
+// exportExecutionGPX downloads the caller's walk as a GPX track with the reached key points as waypoints
+func exportExecutionGPX(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		objID, ok := executionID(w, r)
+		if !ok {
 			return
 		}
 
-		objID, err := primitive.ObjectIDFromHex(execId)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		exec, tourName, kps, err := execs.Replay(ctx, objID, a.UserID)
 		if err != nil {
-			http.Error(w, "invalid execution ID", http.StatusBadRequest)
+			writeRepoError(w, err, "export execution")
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := utils.EncodeExecutionGPX(&buf, tourName, exec, kps); err != nil {
+			log.Println("export execution error:", err)
+			http.Error(w, "failed to export execution", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", routeFileContentTypes[formatGPX])
+		w.Header().Set("Content-Disposition", `attachment; filename="execution-`+exec.ID.Hex()+`.gpx"`)
+		w.Write(buf.Bytes())
+	}
+}
+
+// executionID parses the {execId} route variable, replying 400 when it is malformed
+func executionID(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	objID, err := primitive.ObjectIDFromHex(mux.Vars(r)["execId"])
+	if err != nil {
+		http.Error(w, "invalid execution ID", http.StatusBadRequest)
+		return primitive.NilObjectID, false
+	}
+	return objID, true
+}
+
+type updateExecutionRequest struct {
+	Status string `json:"status"`
+}
+
+func updateExecution(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		objID, ok := executionID(w, r)
+		if !ok {
 			return
 		}
 
@@ -161,40 +272,62 @@ func updateExecution(repo tourExecRepo) http.HandlerFunc {
 			return
 		}
 
-		status := model.ExecutionStatus(req.Status)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
 
-		completedPoints := make([]model.CompletedPoint, 0, len(req.CompletedPoints))
-		now := time.Now().UTC()
-		for _, kpID := range req.CompletedPoints {
-			id, err := primitive.ObjectIDFromHex(kpID)
-			if err != nil {
-				continue
-			}
-			completedPoints = append(completedPoints, model.CompletedPoint{
-				KeyPointID: id,
-				ReachedAt:  now,
-			})
+		if err := execs.UpdateStatus(ctx, objID, a.UserID, model.ExecutionStatus(req.Status)); err != nil {
+			writeRepoError(w, err, "update execution")
+			return
 		}
 
-		var finishedAt *time.Time
-		if status == model.ExecutionCompleted || status == model.ExecutionAbandoned {
-			finishedAt = &now
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// pauseExecution stops tracking the caller's execution for a break that will not count towards its duration
+func pauseExecution(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
 		}
 
-		exec := &model.TourExecution{
-			ID:              objID,
-			Status:          status,
-			LastActivity:    now,
-			CompletedPoints: completedPoints,
-			FinishedAt:      finishedAt,
+		objID, ok := executionID(w, r)
+		if !ok {
+			return
 		}
 
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		if err := repo.UpdateExecution(ctx, exec); err != nil {
-			log.Println("update execution error:", err)
-			http.Error(w, "failed to update execution", http.StatusInternalServerError)
+		if err := execs.Pause(ctx, objID, a.UserID); err != nil {
+			writeRepoError(w, err, "pause execution")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func resumeExecution(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		objID, ok := executionID(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := execs.Resume(ctx, objID, a.UserID); err != nil {
+			writeRepoError(w, err, "resume execution")
 			return
 		}
 
@@ -207,7 +340,9 @@ type addLocationRequest struct {
 	Longitude float64 `json:"longitude"`
 }
 
-func addLocation(repo tourExecRepo) http.HandlerFunc {
+// addLocation records a single position and replies with the events it produced,
+// which are also pushed to any WebSocket following the execution
+func addLocation(execs *service.ExecutionService, hub *executionHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		a := auth.GetAuth(r)
 		if a == nil || a.UserID == "" {
@@ -215,47 +350,67 @@ func addLocation(repo tourExecRepo) http.HandlerFunc {
 			return
 		}
 
-		vars := mux.Vars(r)
-		execId := vars["execId"]
-		objID, _ := primitive.ObjectIDFromHex(execId)
+		objID, ok := executionID(w, r)
+		if !ok {
+			return
+		}
 
 		var req addLocationRequest
-		_ = json.NewDecoder(r.Body).Decode(&req)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
 
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		exec, _ := repo.GetExecutionByID(ctx, objID)
-		kps, _ := repo.GetKeyPointsByTour(ctx, exec.TourID)
+		events, err := execs.RecordLocation(ctx, objID, a.UserID, req.Latitude, req.Longitude)
+		hub.publish(objID, events...)
+		if err != nil {
+			writeRepoError(w, err, "record location")
+			return
+		}
 
-		loc := model.Location{
-			Latitude:  req.Latitude,
-			Longitude: req.Longitude,
-			Timestamp: time.Now().UTC(),
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+	}
+}
+
+// addLocationBatch uploads positions buffered while the tourist was offline, e.g.
+// POST /executions/{execId}/locations:batch with [{"latitude":..,"longitude":..,"timestamp":"..","accuracy":8}]
+func addLocationBatch(execs *service.ExecutionService, hub *executionHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
 		}
 
-		exec.LastActivity = time.Now().UTC()
-		_ = repo.AddLocation(ctx, objID, loc)
+		objID, ok := executionID(w, r)
+		if !ok {
+			return
+		}
 
-		// automatsko kompletiranje keypoints
-		for _, kp := range kps {
-			alreadyCompleted := false
-			for _, cp := range exec.CompletedPoints {
-				if cp.KeyPointID == kp.ID {
-					alreadyCompleted = true
-					break
-				}
-			}
-			if !alreadyCompleted && utils.IsNearby(req.Latitude, req.Longitude, kp.Latitude, kp.Longitude) {
-				cp := model.CompletedPoint{
-					KeyPointID: kp.ID,
-					ReachedAt:  time.Now().UTC(),
-				}
-				_ = repo.CompletePoint(ctx, objID, cp)
-			}
+		var points []service.LocationPoint
+		if err := json.NewDecoder(r.Body).Decode(&points); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
 		}
 
-		w.WriteHeader(http.StatusNoContent)
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		res, err := execs.RecordLocations(ctx, objID, a.UserID, points)
+		if res != nil {
+			hub.publish(objID, res.Events...)
+		}
+		if err != nil {
+			writeRepoError(w, err, "record locations")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
 	}
 }
 
@@ -263,7 +418,9 @@ type completePointRequest struct {
 	KeyPointID string `json:"keyPointId"`
 }
 
-func completePoint(repo tourExecRepo) http.HandlerFunc {
+// completePoint marks a key point reached by hand and replies with the events it produced,
+// including the summary when it was the last one outstanding
+func completePoint(execs *service.ExecutionService, hub *executionHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		a := auth.GetAuth(r)
 		if a == nil || a.UserID == "" {
@@ -271,16 +428,8 @@ func completePoint(repo tourExecRepo) http.HandlerFunc {
 			return
 		}
 
-		vars := mux.Vars(r)
-		execId := vars["execId"]
-		if execId == "" {
-			http.Error(w, "execution ID required", http.StatusBadRequest)
-			return
-		}
-
-		objID, err := primitive.ObjectIDFromHex(execId)
-		if err != nil {
-			http.Error(w, "invalid execution ID", http.StatusBadRequest)
+		objID, ok := executionID(w, r)
+		if !ok {
 			return
 		}
 
@@ -299,56 +448,38 @@ func completePoint(repo tourExecRepo) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		exec, err := repo.GetActiveExecution(ctx, a.UserID, objID)
-		if err != nil {
-			http.Error(w, "active execution not found", http.StatusNotFound)
-			return
-		}
-
-		kps, err := repo.GetKeyPointsByTour(ctx, exec.TourID)
+		events, err := execs.CompletePoint(ctx, objID, a.UserID, kpID)
+		hub.publish(objID, events...)
 		if err != nil {
-			http.Error(w, "failed to get keypoints", http.StatusInternalServerError)
-			return
-		}
-
-		var keypoint model.KeyPoint
-		found := false
-		for _, kp := range kps {
-			if kp.ID == kpID {
-				keypoint = kp
-				found = true
-				break
-			}
-		}
-		if !found {
-			http.Error(w, "keypoint not found", http.StatusBadRequest)
+			writeRepoError(w, err, "complete point")
 			return
 		}
 
-		if len(exec.Locations) == 0 {
-			http.Error(w, "no location recorded", http.StatusBadRequest)
-			return
-		}
-		lastLoc := exec.Locations[len(exec.Locations)-1]
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+	}
+}
 
-		if !utils.IsNearby(lastLoc.Latitude, lastLoc.Longitude, keypoint.Latitude, keypoint.Longitude) {
-			http.Error(w, "too far from keypoint", http.StatusBadRequest)
+// listSuspiciousExecutions shows a guide the executions of their tour whose positions moved
+// implausibly, together with the offending fixes
+func listSuspiciousExecutions(execs *service.ExecutionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		cp := model.CompletedPoint{
-			KeyPointID: kpID,
-			ReachedAt:  time.Now().UTC(),
-		}
-
-		exec.LastActivity = time.Now().UTC()
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
 
-		if err := repo.CompletePoint(ctx, objID, cp); err != nil {
-			log.Println("complete point error:", err)
-			http.Error(w, "failed to complete point", http.StatusInternalServerError)
+		list, err := execs.ListSuspicious(ctx, mux.Vars(r)["id"], a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "list suspicious executions")
 			return
 		}
 
-		w.WriteHeader(http.StatusNoContent)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
 	}
 }
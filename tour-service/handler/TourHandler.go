@@ -3,12 +3,18 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"tour-service/auth"
 	"tour-service/model"
+	"tour-service/repository"
+	"tour-service/utils"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -18,11 +24,17 @@ type tourRepo interface {
 	CreateTour(ctx context.Context, t *model.Tour) (*model.Tour, error)
 	GetTourByID(ctx context.Context, tourId string) (*model.Tour, error)
 	GetToursByAuthor(ctx context.Context, authorId string) ([]model.Tour, error)
-	UpdateTour(ctx context.Context, tourId string, authorId string, updates map[string]interface{}) (*model.Tour, error)
+	UpdateTour(ctx context.Context, tourId string, authorId string, updates map[string]interface{}) (*model.Tour, bool, error)
 	GetKeyPointsByTour(ctx context.Context, tourId primitive.ObjectID) ([]model.KeyPoint, error)
 	PublishTour(ctx context.Context, tourId string, authorId string) (*model.Tour, error)
 	ArchiveTour(ctx context.Context, tourId string, authorId string) (*model.Tour, error)
 	ActivateTour(ctx context.Context, tourId string, authorId string) (*model.Tour, error)
+	PublishTourRevision(ctx context.Context, tourId string, authorId string) (*model.Tour, error)
+	DiscardTourRevision(ctx context.Context, tourId string, authorId string) error
+	CloneTour(ctx context.Context, tourId string, userId string) (*model.Tour, error)
+	SearchTours(ctx context.Context, f model.TourSearchFilter) (*model.TourSearchResult, error)
+	SearchNearby(ctx context.Context, lat, lng, radius float64, mode string, limit int) ([]model.NearbyTour, error)
+	RecalculateRoute(ctx context.Context, tourId primitive.ObjectID) (*model.Tour, error)
 }
 
 func RegisterRoutes(public *mux.Router, authRouter *mux.Router, repo tourRepo) {
@@ -33,8 +45,14 @@ func RegisterRoutes(public *mux.Router, authRouter *mux.Router, repo tourRepo) {
 		authRouter.HandleFunc("/tours/{id}/publish", publishTour(repo)).Methods("POST")
 		authRouter.HandleFunc("/tours/{id}/archive", archiveTour(repo)).Methods("POST")
 		authRouter.HandleFunc("/tours/{id}/activate", activateTour(repo)).Methods("POST")
+		authRouter.HandleFunc("/tours/{id}/revision", getTourRevision(repo)).Methods("GET")
+		authRouter.HandleFunc("/tours/{id}/revision/publish", publishTourRevision(repo)).Methods("POST")
+		authRouter.HandleFunc("/tours/{id}/revision", discardTourRevision(repo)).Methods("DELETE")
+		authRouter.HandleFunc("/tours/{id}/clone", cloneTour(repo)).Methods("POST")
 	}
 	// public routes
+	public.HandleFunc("/tours", searchTours(repo)).Methods("GET")
+	public.HandleFunc("/tours/nearby", searchNearbyTours(repo)).Methods("GET")
 	public.HandleFunc("/tours/{id}", getTourByID(repo)).Methods("GET")
 	public.HandleFunc("/tours/author/{authorId}", listToursByAuthor(repo)).Methods("GET")
 }
@@ -46,6 +64,9 @@ type createTourRequest struct {
 	Tags        []string                 `json:"tags"`
 	Status      string                   `json:"status"`
 	Durations   *model.TransportDuration `json:"durations,omitempty"`
+	Forkable    bool                     `json:"forkable"`
+	// SequentialKeyPoints makes tourists reach the key points in order
+	SequentialKeyPoints bool `json:"sequentialKeyPoints"`
 }
 
 func createTour(repo tourRepo) http.HandlerFunc {
@@ -66,13 +87,18 @@ func createTour(repo tourRepo) http.HandlerFunc {
 			http.Error(w, "name is required", http.StatusBadRequest)
 			return
 		}
+		if req.Status != "" && model.TourStatus(req.Status) != model.TourDraft {
+			http.Error(w, "new tours start as draft, use /tours/{id}/publish", http.StatusBadRequest)
+			return
+		}
 		t := &model.Tour{
-			AuthorID:    a.UserID,
-			Name:        req.Name,
-			Description: req.Description,
-			Difficulty:  req.Difficulty,
-			Tags:        req.Tags,
-			Status:      req.Status,
+			AuthorID:            a.UserID,
+			Name:                req.Name,
+			Description:         req.Description,
+			Difficulty:          req.Difficulty,
+			Tags:                req.Tags,
+			Forkable:            req.Forkable,
+			SequentialKeyPoints: req.SequentialKeyPoints,
 		}
 		if req.Durations != nil {
 			t.Durations = *req.Durations
@@ -98,8 +124,11 @@ type updateTourRequest struct {
 	Tags        []string                 `json:"tags"`
 	Status      string                   `json:"status"`
 	Price       float64                  `json:"price"`
-	Distance    float64                  `json:"distance"`
 	Durations   *model.TransportDuration `json:"durations,omitempty"`
+	// AutoDurations drops a manual override and goes back to estimating durations from the route
+	AutoDurations       bool  `json:"autoDurations,omitempty"`
+	Forkable            *bool `json:"forkable,omitempty"`
+	SequentialKeyPoints *bool `json:"sequentialKeyPoints,omitempty"`
 }
 
 func updateTour(repo tourRepo) http.HandlerFunc {
@@ -139,26 +168,47 @@ func updateTour(repo tourRepo) http.HandlerFunc {
 			updates["tags"] = req.Tags
 		}
 		if req.Status != "" {
-			updates["status"] = req.Status
+			// only accepted when unchanged, the repository rejects anything else
+			updates["status"] = model.TourStatus(req.Status)
 		}
 		if req.Price > 0 {
 			updates["price"] = req.Price
 		}
-		if req.Distance > 0 {
-			updates["distance"] = req.Distance
+		if req.Forkable != nil {
+			updates["forkable"] = *req.Forkable
+		}
+		if req.SequentialKeyPoints != nil {
+			updates["sequentialKeyPoints"] = *req.SequentialKeyPoints
 		}
+		// distance is always derived from the key points; durations only until the guide overrides them
 		if req.Durations != nil {
 			updates["durations"] = req.Durations
+			updates["durationsOverride"] = true
+		} else if req.AutoDurations {
+			updates["durationsOverride"] = false
 		}
 
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
-		updated, err := repo.UpdateTour(ctx, tourId, a.UserID, updates)
+		updated, staged, err := repo.UpdateTour(ctx, tourId, a.UserID, updates)
 		if err != nil {
-			log.Println("update tour error:", err)
-			http.Error(w, "failed to update tour", http.StatusInternalServerError)
+			writeRepoError(w, err, "update tour")
 			return
 		}
+		// published and archived tours keep their live fields; the edit waits in the pending revision
+		if staged {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(updated.PendingRevision)
+			return
+		}
+		if req.Durations == nil && req.AutoDurations {
+			if recalculated, err := repo.RecalculateRoute(ctx, updated.ID); err != nil {
+				log.Println("recalculate route error:", err)
+			} else {
+				updated = recalculated
+			}
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(updated)
 	}
@@ -206,6 +256,178 @@ func listToursByAuthor(repo tourRepo) http.HandlerFunc {
 	}
 }
 
+// searchTours lists published tours, e.g.
+// GET /tours?difficulty=easy,medium&tags=history,food&tagMatch=all&maxPrice=20&transport=biking&maxDuration=90&sort=price_asc&limit=10
+func searchTours(repo tourRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := parseTourSearchFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		res, err := repo.SearchTours(ctx, f)
+		if err != nil {
+			if errors.Is(err, repository.ErrInvalidCursor) || errors.Is(err, repository.ErrInvalidSort) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Println("search tours error:", err)
+			http.Error(w, "failed to search tours", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+const defaultNearbyRadius = 5000.0 // meters
+
+// searchNearbyTours lists published tours close to a point, e.g.
+// GET /tours/nearby?lat=44.81&lng=20.46&radius=5000&mode=start
+func searchNearbyTours(repo tourRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		lat, err := floatParam(q, "lat")
+		if err != nil || lat == nil {
+			http.Error(w, "lat required", http.StatusBadRequest)
+			return
+		}
+		lng, err := floatParam(q, "lng")
+		if err != nil || lng == nil {
+			http.Error(w, "lng required", http.StatusBadRequest)
+			return
+		}
+		if !utils.ValidCoordinates(*lat, *lng) {
+			http.Error(w, "coordinates out of range", http.StatusBadRequest)
+			return
+		}
+		radius := defaultNearbyRadius
+		if v, err := floatParam(q, "radius"); err != nil || (v != nil && *v <= 0) {
+			http.Error(w, "invalid radius", http.StatusBadRequest)
+			return
+		} else if v != nil {
+			radius = *v
+		}
+		mode := q.Get("mode")
+		if mode == "" {
+			mode = model.NearbyStart
+		}
+		if mode != model.NearbyStart && mode != model.NearbyAny {
+			http.Error(w, "mode must be start or any", http.StatusBadRequest)
+			return
+		}
+		limit, err := intParam(q, "limit")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n := 0
+		if limit != nil {
+			n = *limit
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		tours, err := repo.SearchNearby(ctx, *lat, *lng, radius, mode, n)
+		if err != nil {
+			log.Println("search nearby tours error:", err)
+			http.Error(w, "failed to search tours", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tours)
+	}
+}
+
+func parseTourSearchFilter(q url.Values) (model.TourSearchFilter, error) {
+	f := model.TourSearchFilter{
+		Difficulties: splitList(q["difficulty"]),
+		Tags:         splitList(q["tags"]),
+		Transport:    q.Get("transport"),
+		Sort:         q.Get("sort"),
+		Cursor:       q.Get("cursor"),
+	}
+	switch q.Get("tagMatch") {
+	case "", "any":
+	case "all":
+		f.MatchAllTags = true
+	default:
+		return f, errors.New("tagMatch must be any or all")
+	}
+	switch f.Transport {
+	case "", "walking", "biking", "driving":
+	default:
+		return f, errors.New("transport must be walking, biking or driving")
+	}
+
+	var err error
+	if f.MinPrice, err = floatParam(q, "minPrice"); err != nil {
+		return f, err
+	}
+	if f.MaxPrice, err = floatParam(q, "maxPrice"); err != nil {
+		return f, err
+	}
+	if f.MinDistance, err = floatParam(q, "minDistance"); err != nil {
+		return f, err
+	}
+	if f.MaxDistance, err = floatParam(q, "maxDistance"); err != nil {
+		return f, err
+	}
+	if f.MinDuration, err = intParam(q, "minDuration"); err != nil {
+		return f, err
+	}
+	if f.MaxDuration, err = intParam(q, "maxDuration"); err != nil {
+		return f, err
+	}
+	limit, err := intParam(q, "limit")
+	if err != nil {
+		return f, err
+	}
+	if limit != nil {
+		f.Limit = *limit
+	}
+	return f, nil
+}
+
+// splitList accepts both repeated (?tags=a&tags=b) and comma separated (?tags=a,b) values.
+func splitList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+func floatParam(q url.Values, name string) (*float64, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, errors.New("invalid " + name)
+	}
+	return &v, nil
+}
+
+func intParam(q url.Values, name string) (*int, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, errors.New("invalid " + name)
+	}
+	return &v, nil
+}
+
 func publishTour(repo tourRepo) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		a := auth.GetAuth(r)
@@ -235,10 +457,14 @@ func publishTour(repo tourRepo) http.HandlerFunc {
 			http.Error(w, "forbidden", http.StatusForbidden)
 			return
 		}
+		if tour.Status != model.TourDraft {
+			http.Error(w, "only draft tours can be published, use /tours/{id}/activate for archived tours", http.StatusConflict)
+			return
+		}
 
 		// Check requirements
-		if tour.Name == "" || tour.Description == "" || tour.Difficulty == "" || len(tour.Tags) == 0 {
-			http.Error(w, "tour missing basic information", http.StatusBadRequest)
+		if msg := publishProblem(tour); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
 			return
 		}
 
@@ -255,15 +481,9 @@ func publishTour(repo tourRepo) http.HandlerFunc {
 			return
 		}
 
-		if tour.Durations.Walking == 0 && tour.Durations.Biking == 0 && tour.Durations.Driving == 0 {
-			http.Error(w, "tour must have at least one duration defined", http.StatusBadRequest)
-			return
-		}
-
 		published, err := repo.PublishTour(ctx, tourId, a.UserID)
 		if err != nil {
-			log.Println("publish tour error:", err)
-			http.Error(w, "failed to publish tour", http.StatusInternalServerError)
+			writeRepoError(w, err, "publish tour")
 			return
 		}
 
@@ -292,8 +512,7 @@ func archiveTour(repo tourRepo) http.HandlerFunc {
 
 		archived, err := repo.ArchiveTour(ctx, tourId, a.UserID)
 		if err != nil {
-			log.Println("archive tour error:", err)
-			http.Error(w, "failed to archive tour", http.StatusInternalServerError)
+			writeRepoError(w, err, "archive tour")
 			return
 		}
 
@@ -322,8 +541,7 @@ func activateTour(repo tourRepo) http.HandlerFunc {
 
 		activated, err := repo.ActivateTour(ctx, tourId, a.UserID)
 		if err != nil {
-			log.Println("activate tour error:", err)
-			http.Error(w, "failed to activate tour", http.StatusInternalServerError)
+			writeRepoError(w, err, "activate tour")
 			return
 		}
 
@@ -331,3 +549,131 @@ func activateTour(repo tourRepo) http.HandlerFunc {
 		json.NewEncoder(w).Encode(activated)
 	}
 }
+
+// publishProblem describes why the tour fields are not ready to go live, or returns ""
+func publishProblem(tour *model.Tour) string {
+	if tour.Name == "" || tour.Description == "" || tour.Difficulty == "" || len(tour.Tags) == 0 {
+		return "tour missing basic information"
+	}
+	if tour.Durations.Walking == 0 && tour.Durations.Biking == 0 && tour.Durations.Driving == 0 {
+		return "tour must have at least one duration defined"
+	}
+	return ""
+}
+
+// getTourRevision shows the author the edits waiting to go live on a published tour
+func getTourRevision(repo tourRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		tour, err := authorizeTourAuthor(ctx, repo, mux.Vars(r)["id"], a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "get tour")
+			return
+		}
+		if tour.PendingRevision == nil {
+			http.Error(w, repository.ErrNoPendingRevision.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tour.PendingRevision)
+	}
+}
+
+func publishTourRevision(repo tourRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		vars := mux.Vars(r)
+		tourId := vars["id"]
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		tour, err := authorizeTourAuthor(ctx, repo, tourId, a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "get tour")
+			return
+		}
+		if tour.PendingRevision == nil {
+			http.Error(w, repository.ErrNoPendingRevision.Error(), http.StatusNotFound)
+			return
+		}
+		// the revised tour has to meet the same requirements as a first publish
+		tour.PendingRevision.ApplyTo(tour)
+		if msg := publishProblem(tour); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		keypoints, err := editedKeyPoints(ctx, repo, tour)
+		if err != nil || len(keypoints) < 2 {
+			http.Error(w, "tour must have at least 2 key points", http.StatusBadRequest)
+			return
+		}
+
+		updated, err := repo.PublishTourRevision(ctx, tourId, a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "publish revision")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+	}
+}
+
+func discardTourRevision(repo tourRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := repo.DiscardTourRevision(ctx, mux.Vars(r)["id"], a.UserID); err != nil {
+			writeRepoError(w, err, "discard revision")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// cloneTour copies a tour and its key points into a new draft owned by the caller. Authors can
+// clone their own tours; anyone else only tours the author marked as forkable.
+func cloneTour(repo tourRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		clone, err := repo.CloneTour(ctx, mux.Vars(r)["id"], a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "clone tour")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(clone)
+	}
+}
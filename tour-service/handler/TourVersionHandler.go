@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tour-service/auth"
+	"tour-service/model"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type tourVersionRepo interface {
+	GetTourByID(ctx context.Context, tourId string) (*model.Tour, error)
+	GetTourVersions(ctx context.Context, tourId primitive.ObjectID) ([]model.TourVersion, error)
+	GetTourVersion(ctx context.Context, tourId primitive.ObjectID, version int) (*model.TourVersion, error)
+	RollbackTour(ctx context.Context, tourId string, authorId string, version int) (*model.Tour, error)
+}
+
+// RegisterTourVersionRoutes exposes the publish history of a tour to its author
+func RegisterTourVersionRoutes(authRouter *mux.Router, repo tourVersionRepo) {
+	if authRouter != nil {
+		authRouter.HandleFunc("/tours/{id}/versions", listTourVersions(repo)).Methods("GET")
+		authRouter.HandleFunc("/tours/{id}/versions/diff", diffTourVersions(repo)).Methods("GET")
+		authRouter.HandleFunc("/tours/{id}/versions/{version:[0-9]+}", getTourVersion(repo)).Methods("GET")
+		authRouter.HandleFunc("/tours/{id}/versions/{version:[0-9]+}/rollback", rollbackTour(repo)).Methods("POST")
+	}
+}
+
+func listTourVersions(repo tourVersionRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		tour, err := authorizeTourAuthor(ctx, repo, mux.Vars(r)["id"], a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "get tour")
+			return
+		}
+		versions, err := repo.GetTourVersions(ctx, tour.ID)
+		if err != nil {
+			writeRepoError(w, err, "list versions")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(versions)
+	}
+}
+
+func getTourVersion(repo tourVersionRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		vars := mux.Vars(r)
+		version, _ := strconv.Atoi(vars["version"])
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		tour, err := authorizeTourAuthor(ctx, repo, vars["id"], a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "get tour")
+			return
+		}
+		v, err := repo.GetTourVersion(ctx, tour.ID, version)
+		if err != nil {
+			writeRepoError(w, err, "get version")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+// diffTourVersions compares two versions, e.g. GET /tours/{id}/versions/diff?from=2&to=3.
+// to defaults to the current version.
+func diffTourVersions(repo tourVersionRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		q := r.URL.Query()
+		from, err := intParam(q, "from")
+		if err != nil || from == nil {
+			http.Error(w, "from version required", http.StatusBadRequest)
+			return
+		}
+		to, err := intParam(q, "to")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		tour, err := authorizeTourAuthor(ctx, repo, mux.Vars(r)["id"], a.UserID)
+		if err != nil {
+			writeRepoError(w, err, "get tour")
+			return
+		}
+		if to == nil {
+			to = &tour.CurrentVersion
+		}
+
+		fromVersion, err := repo.GetTourVersion(ctx, tour.ID, *from)
+		if err != nil {
+			writeRepoError(w, err, "get version")
+			return
+		}
+		toVersion, err := repo.GetTourVersion(ctx, tour.ID, *to)
+		if err != nil {
+			writeRepoError(w, err, "get version")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(model.DiffTourVersions(fromVersion, toVersion))
+	}
+}
+
+// rollbackTour restores an older version as the newest one, leaving the tour status as it is
+func rollbackTour(repo tourVersionRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := auth.GetAuth(r)
+		if a == nil || a.UserID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		vars := mux.Vars(r)
+		version, _ := strconv.Atoi(vars["version"])
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		tour, err := repo.RollbackTour(ctx, vars["id"], a.UserID, version)
+		if err != nil {
+			writeRepoError(w, err, "roll back tour")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tour)
+	}
+}
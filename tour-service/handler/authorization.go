@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"tour-service/model"
+	"tour-service/repository"
+	"tour-service/service"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type tourGetter interface {
+	GetTourByID(ctx context.Context, tourId string) (*model.Tour, error)
+}
+
+// authorizeTourAuthor loads the tour and checks that userId is its author.
+// It returns repository.ErrTourNotFound or repository.ErrForbidden otherwise.
+func authorizeTourAuthor(ctx context.Context, repo tourGetter, tourId string, userId string) (*model.Tour, error) {
+	tour, err := repo.GetTourByID(ctx, tourId)
+	if errors.Is(err, primitive.ErrInvalidHex) {
+		return nil, repository.ErrTourNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tour.AuthorID != userId {
+		return nil, repository.ErrForbidden
+	}
+	return tour, nil
+}
+
+// writeRepoError maps repository errors to 4xx statuses and logs anything unexpected as a 500 with msg
+func writeRepoError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, repository.ErrTourNotFound), errors.Is(err, repository.ErrKeyPointNotFound),
+		errors.Is(err, repository.ErrReviewNotFound), errors.Is(err, repository.ErrExecutionNotFound),
+		errors.Is(err, repository.ErrGroupNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repository.ErrForbidden), errors.Is(err, repository.ErrOwnReview),
+		errors.Is(err, service.ErrTourNotPurchased):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, repository.ErrInvalidTransition), errors.Is(err, repository.ErrConcurrentTourEdit),
+		errors.Is(err, repository.ErrExecutionNotActive), errors.Is(err, repository.ErrExecutionNotPaused),
+		errors.Is(err, repository.ErrActiveExecutionExists), errors.Is(err, service.ErrKeyPointOutOfOrder),
+		errors.Is(err, repository.ErrGroupClosed), errors.Is(err, repository.ErrGroupFull):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repository.ErrNoPendingRevision), errors.Is(err, repository.ErrVersionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repository.ErrStatusNotEditable), errors.Is(err, service.ErrInvalidCoordinates),
+		errors.Is(err, service.ErrInvalidStatus), errors.Is(err, service.ErrInvalidTransportMode),
+		errors.Is(err, service.ErrTourNotStartable),
+		errors.Is(err, service.ErrKeyPointNotInTour), errors.Is(err, service.ErrNoLocation),
+		errors.Is(err, service.ErrTooFarFromKeyPoint), errors.Is(err, service.ErrEmptyBatch),
+		errors.Is(err, service.ErrInvalidGroupSize):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrBatchTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		log.Println(msg+" error:", err)
+		http.Error(w, "failed to "+msg, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"sync"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// executionHub fans execution events out to every live connection following that execution,
+// so positions posted over HTTP still reach a tourist's open WebSocket
+type executionHub struct {
+	mu   sync.Mutex
+	subs map[primitive.ObjectID]map[chan model.ExecutionEvent]struct{}
+}
+
+func newExecutionHub() *executionHub {
+	return &executionHub{subs: make(map[primitive.ObjectID]map[chan model.ExecutionEvent]struct{})}
+}
+
+func (h *executionHub) subscribe(execId primitive.ObjectID) chan model.ExecutionEvent {
+	ch := make(chan model.ExecutionEvent, 32)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[execId] == nil {
+		h.subs[execId] = make(map[chan model.ExecutionEvent]struct{})
+	}
+	h.subs[execId][ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe removes and closes the channel
+func (h *executionHub) unsubscribe(execId primitive.ObjectID, ch chan model.ExecutionEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[execId][ch]; !ok {
+		return
+	}
+	delete(h.subs[execId], ch)
+	if len(h.subs[execId]) == 0 {
+		delete(h.subs, execId)
+	}
+	close(ch)
+}
+
+// publish never blocks: a subscriber that has fallen behind misses the events
+func (h *executionHub) publish(execId primitive.ObjectID, events ...model.ExecutionEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[execId] {
+		for _, ev := range events {
+			select {
+			case ch <- ev:
+			default:
+			}
+		}
+	}
+}
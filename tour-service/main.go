@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -14,6 +15,8 @@ import (
 	tourgrpc "tour-service/grpc"
 	"tour-service/handler"
 	"tour-service/repository"
+	"tour-service/service"
+	"tour-service/utils"
 
 	pb "github.com/IvanNovakovic/SOA_Proj/protos"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -115,6 +118,10 @@ func main() {
 		}).Fatal("Failed to connect to MongoDB")
 	}
 	defer repo.Close(context.Background())
+	repo.SetTravelSpeeds(utils.TravelSpeedsFromEnv())
+	if n, err := strconv.Atoi(os.Getenv("REVIEW_REPORT_THRESHOLD")); err == nil && n > 0 {
+		repo.SetReportThreshold(n)
+	}
 
 	logger.WithFields(logrus.Fields{
 		"service": "tour-service",
@@ -143,8 +150,43 @@ func main() {
 
 	handler.RegisterRoutes(r, authSub, repo)
 	handler.RegisterKeyPointRoutes(r, authSub, repo)
+	handler.RegisterRouteFileRoutes(r, authSub, repo)
+	handler.RegisterTourVersionRoutes(authSub, repo)
 	handler.RegisterReviewRoutes(r, authSub, repo)
-	handler.RegisterExecutionRoutes(authSub, repo)
+	execService := service.NewExecutionService(repo)
+	if n, err := strconv.Atoi(os.Getenv("KEYPOINT_DWELL_SECONDS")); err == nil && n > 0 {
+		execService.SetDwellTime(time.Duration(n) * time.Second)
+	}
+	// followers of a tourist may watch the executions they share, e.g. FOLLOWER_GRPC_ADDR=follower-service:9092
+	followerAddr := os.Getenv("FOLLOWER_GRPC_ADDR")
+	if followerAddr == "" {
+		followerAddr = "follower-service:9092"
+	}
+	followers, err := tourgrpc.NewFollowerClient(followerAddr)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"service": "tour-service",
+			"action":  "grpc_client_init",
+			"target":  "follower-service",
+			"error":   err.Error(),
+		}).Error("Failed to connect to follower service, sharing with followers is disabled")
+	} else {
+		defer followers.Close()
+		execService.SetFollowerChecker(followers)
+	}
+	handler.RegisterExecutionRoutes(r, authSub, execService)
+
+	// Abandon executions whose tourist went quiet, e.g. EXECUTION_IDLE_TIMEOUT=2h
+	idleTimeout, _ := time.ParseDuration(os.Getenv("EXECUTION_IDLE_TIMEOUT"))
+	pauseTimeout, _ := time.ParseDuration(os.Getenv("EXECUTION_PAUSE_TIMEOUT"))
+	sweepInterval, _ := time.ParseDuration(os.Getenv("EXECUTION_SWEEP_INTERVAL"))
+	sweeper := service.NewExecutionSweeper(repo, idleTimeout, pauseTimeout, sweepInterval)
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	sweeperDone := make(chan struct{})
+	go func() {
+		defer close(sweeperDone)
+		sweeper.Run(sweepCtx)
+	}()
 
 	// Start gRPC server
 	grpcPort := os.Getenv("GRPC_PORT")
@@ -216,6 +258,8 @@ func main() {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	srv.Shutdown(shutdownCtx)
+	stopSweeper()
+	<-sweeperDone
 	logger.WithFields(logrus.Fields{
 		"service": "tour-service",
 		"action":  "shutdown",
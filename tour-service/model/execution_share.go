@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExecutionShare lets others watch an execution while it is in progress. Only a hash of the
+// token is kept; the tourist sees the token once, when sharing starts.
+type ExecutionShare struct {
+	TokenHash string    `bson:"tokenHash" json:"-"`
+	Followers bool      `bson:"followers" json:"followers"` // the tourist's followers may watch without the token
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+// ShareGrant is handed to the tourist when they start sharing
+type ShareGrant struct {
+	Token     string    `json:"token"`
+	Followers bool      `json:"followers"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SharedExecution is the read-only view of an execution shown to the people it is shared with.
+// KeyPoints are only the key points already reached, in tour order.
+type SharedExecution struct {
+	ExecutionID     primitive.ObjectID `json:"executionId"`
+	TourID          primitive.ObjectID `json:"tourId"`
+	TouristID       string             `json:"touristId"`
+	Status          ExecutionStatus    `json:"status"`
+	StartedAt       time.Time          `json:"startedAt"`
+	LastLocation    *Location          `json:"lastLocation,omitempty"`
+	Locations       []Location         `json:"locations"`
+	CompletedPoints []CompletedPoint   `json:"completedPoints"`
+	KeyPoints       []KeyPoint         `json:"keyPoints"`
+	Progress        float64            `json:"progress"` // percentage of key points completed
+	ExpiresAt       time.Time          `json:"expiresAt"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type GroupSessionStatus string
+
+const (
+	GroupSessionOpen   GroupSessionStatus = "open"
+	GroupSessionClosed GroupSessionStatus = "closed"
+)
+
+// GroupSession is a guided walk of a tour; tourists join it with its code while it is open and
+// each of them takes the tour in their own execution linked to the session
+type GroupSession struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TourID  primitive.ObjectID `bson:"tourId" json:"tourId"`
+	GuideID string             `bson:"guideId" json:"guideId"`
+	// TourVersion is pinned when the session starts so every member follows the same key points
+	TourVersion int                `bson:"tourVersion" json:"tourVersion"`
+	JoinCode    string             `bson:"joinCode" json:"joinCode"`
+	Status      GroupSessionStatus `bson:"status" json:"status"`
+	// MaxMembers caps how many tourists may join; Joined counts those who did
+	MaxMembers int       `bson:"maxMembers" json:"maxMembers"`
+	Joined     int       `bson:"joined" json:"joined"`
+	CreatedAt  time.Time `bson:"createdAt" json:"createdAt"`
+	// ExpiresAt closes the session to new members even if the guide never does
+	ExpiresAt time.Time  `bson:"expiresAt" json:"expiresAt"`
+	ClosedAt  *time.Time `bson:"closedAt,omitempty" json:"closedAt,omitempty"`
+}
+
+// GroupMember is what the guide sees of one member during the walk
+type GroupMember struct {
+	TouristID       string             `json:"touristId"`
+	ExecutionID     primitive.ObjectID `json:"executionId"`
+	Status          ExecutionStatus    `json:"status"`
+	LastLocation    *Location          `json:"lastLocation,omitempty"`
+	CompletedPoints []CompletedPoint   `json:"completedPoints"`
+	Progress        float64            `json:"progress"` // percentage of key points completed
+	LastActivity    time.Time          `json:"lastActivity"`
+}
+
+// GroupView is the guide's live view of a session
+type GroupView struct {
+	GroupSession
+	Members []GroupMember `json:"members"`
+}
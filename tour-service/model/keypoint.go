@@ -14,6 +14,70 @@ type KeyPoint struct {
 	ImageURL    string             `bson:"imageUrl,omitempty" json:"imageUrl,omitempty"`
 	Latitude    float64            `bson:"latitude" json:"latitude"`
 	Longitude   float64            `bson:"longitude" json:"longitude"`
+	Location    GeoPoint           `bson:"location" json:"-"` // GeoJSON copy of latitude/longitude for 2dsphere queries
 	Order       int                `bson:"order" json:"order"`
 	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	// Radius in meters within which the key point counts as reached, 0 for the default.
+	// A Geofence, for sites too large or irregular for a circle, takes precedence over it.
+	Radius   float64     `bson:"radius,omitempty" json:"radius,omitempty"`
+	Geofence *GeoPolygon `bson:"geofence,omitempty" json:"geofence,omitempty"`
+}
+
+// KeyPointUpdate lists the key point fields a guide may change; nil fields are left untouched.
+// Ownership fields (id, tourId, createdAt) are deliberately absent.
+type KeyPointUpdate struct {
+	Name        *string  `json:"name,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	ImageURL    *string  `json:"imageUrl,omitempty"`
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
+	Radius      *float64 `json:"radius,omitempty"` // 0 goes back to the default radius
+	// Geofence replaces the key point's polygon; one without coordinates removes it
+	Geofence *GeoPolygon `json:"geofence,omitempty"`
+}
+
+// ApplyTo copies the changed fields onto kp and rebuilds its location
+func (u KeyPointUpdate) ApplyTo(kp *KeyPoint) {
+	if u.Name != nil {
+		kp.Name = *u.Name
+	}
+	if u.Description != nil {
+		kp.Description = *u.Description
+	}
+	if u.ImageURL != nil {
+		kp.ImageURL = *u.ImageURL
+	}
+	if u.Latitude != nil {
+		kp.Latitude = *u.Latitude
+	}
+	if u.Longitude != nil {
+		kp.Longitude = *u.Longitude
+	}
+	if u.Radius != nil {
+		kp.Radius = *u.Radius
+	}
+	if u.Geofence != nil {
+		kp.Geofence = nil
+		if len(u.Geofence.Coordinates) > 0 {
+			kp.Geofence = u.Geofence
+		}
+	}
+	kp.Location = NewGeoPoint(kp.Latitude, kp.Longitude)
+}
+
+// GeoPoint is a GeoJSON point. Coordinates are [longitude, latitude] as required by MongoDB.
+type GeoPoint struct {
+	Type        string    `bson:"type" json:"type"`
+	Coordinates []float64 `bson:"coordinates" json:"coordinates"`
+}
+
+func NewGeoPoint(lat, lng float64) GeoPoint {
+	return GeoPoint{Type: "Point", Coordinates: []float64{lng, lat}}
+}
+
+// GeoPolygon is a GeoJSON polygon of [longitude, latitude] positions. Only the outer ring,
+// the first one, is used; it must be closed.
+type GeoPolygon struct {
+	Type        string        `bson:"type" json:"type"`
+	Coordinates [][][]float64 `bson:"coordinates" json:"coordinates"`
 }
@@ -0,0 +1,49 @@
+package model
+
+import "math"
+
+// RatingSummary is the review aggregate kept on each tour
+type RatingSummary struct {
+	Average   float64         `bson:"average" json:"average"`
+	Count     int             `bson:"count" json:"count"`
+	Sum       int             `bson:"sum" json:"-"`
+	Histogram RatingHistogram `bson:"histogram" json:"histogram"`
+}
+
+// RatingHistogram counts reviews per star rating
+type RatingHistogram struct {
+	One   int `bson:"1" json:"1"`
+	Two   int `bson:"2" json:"2"`
+	Three int `bson:"3" json:"3"`
+	Four  int `bson:"4" json:"4"`
+	Five  int `bson:"5" json:"5"`
+}
+
+// Counts returns the histogram as a slice where index 0 holds the one-star reviews
+func (h RatingHistogram) Counts() []int {
+	return []int{h.One, h.Two, h.Three, h.Four, h.Five}
+}
+
+// Add records n reviews with the given star rating (n may be negative) and refreshes the average
+func (s *RatingSummary) Add(rating int, n int) {
+	switch rating {
+	case 1:
+		s.Histogram.One += n
+	case 2:
+		s.Histogram.Two += n
+	case 3:
+		s.Histogram.Three += n
+	case 4:
+		s.Histogram.Four += n
+	case 5:
+		s.Histogram.Five += n
+	default:
+		return
+	}
+	s.Count += n
+	s.Sum += rating * n
+	s.Average = 0
+	if s.Count > 0 {
+		s.Average = math.Round(float64(s.Sum)/float64(s.Count)*100) / 100
+	}
+}
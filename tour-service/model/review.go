@@ -1,19 +1,110 @@
 package model
 
 import (
-    "time"
+	"time"
 
-    "go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Review struct {
-    ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-    TourID     primitive.ObjectID `bson:"tourId" json:"tourId"`
-    AuthorID   string             `bson:"authorId" json:"authorId"`
-    AuthorName string             `bson:"authorName,omitempty" json:"authorName,omitempty"`
-    Rating     int                `bson:"rating" json:"rating"` // 1-5
-    Comment    string             `bson:"comment,omitempty" json:"comment,omitempty"`
-    Images     []string           `bson:"images,omitempty" json:"images,omitempty"`
-    VisitedAt  *time.Time         `bson:"visitedAt,omitempty" json:"visitedAt,omitempty"` // when tourist visited
-    CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`                     // when review posted
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TourID     primitive.ObjectID `bson:"tourId" json:"tourId"`
+	AuthorID   string             `bson:"authorId" json:"authorId"`
+	AuthorName string             `bson:"authorName,omitempty" json:"authorName,omitempty"`
+	Rating     int                `bson:"rating" json:"rating"` // 1-5
+	Comment    string             `bson:"comment,omitempty" json:"comment,omitempty"`
+	Images     []string           `bson:"images,omitempty" json:"images,omitempty"`
+	VisitedAt  *time.Time         `bson:"visitedAt,omitempty" json:"visitedAt,omitempty"` // when tourist visited
+	// ExecutionID links the tour execution that proves the author took the tour; such reviews are verified
+	ExecutionID *primitive.ObjectID `bson:"executionId,omitempty" json:"executionId,omitempty"`
+	Verified    bool                `bson:"verified" json:"verified"`
+	CreatedAt   time.Time           `bson:"createdAt" json:"createdAt"` // when review posted
+	EditedAt    *time.Time          `bson:"editedAt,omitempty" json:"editedAt,omitempty"`
+	// Reply is the tour guide's public answer, at most one per review
+	Reply          *ReviewReply `bson:"reply,omitempty" json:"reply,omitempty"`
+	HelpfulCount   int          `bson:"helpfulCount" json:"helpfulCount"`
+	UnhelpfulCount int          `bson:"unhelpfulCount" json:"unhelpfulCount"`
+	// ReportCount counts open reports; Hidden reviews are left out of listings and the rating aggregate.
+	// Only moderators see them, through AdminReview.
+	ReportCount  int    `bson:"reportCount" json:"-"`
+	Hidden       bool   `bson:"hidden" json:"-"`
+	HiddenReason string `bson:"hiddenReason,omitempty" json:"-"`
+}
+
+// AdminReview is a review as moderators see it, with the moderation state public responses leave out
+type AdminReview struct {
+	Review
+	ReportCount  int    `json:"reportCount"`
+	Hidden       bool   `json:"hidden"`
+	HiddenReason string `json:"hiddenReason,omitempty"`
+}
+
+func NewAdminReview(r Review) AdminReview {
+	return AdminReview{Review: r, ReportCount: r.ReportCount, Hidden: r.Hidden, HiddenReason: r.HiddenReason}
+}
+
+const (
+	HiddenByReports = "reports"
+	HiddenByAdmin   = "admin"
+)
+
+const (
+	ReportSpam          = "spam"
+	ReportOffensive     = "offensive"
+	ReportInappropriate = "inappropriate_image"
+	ReportOther         = "other"
+)
+
+// ReviewReport is a user's complaint about a review, open until an admin hides or restores the review
+type ReviewReport struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ReviewID   primitive.ObjectID `bson:"reviewId" json:"reviewId"`
+	ReporterID string             `bson:"reporterId" json:"reporterId"`
+	Reason     string             `bson:"reason" json:"reason"`
+	Comment    string             `bson:"comment,omitempty" json:"comment,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	Resolved   bool               `bson:"resolved" json:"resolved"`
+	ResolvedBy string             `bson:"resolvedBy,omitempty" json:"resolvedBy,omitempty"`
+	ResolvedAt *time.Time         `bson:"resolvedAt,omitempty" json:"resolvedAt,omitempty"`
+}
+
+// ModerationItem is a reported review in the admin queue with its open reports
+type ModerationItem struct {
+	Review  AdminReview    `json:"review"`
+	Reports []ReviewReport `json:"reports"`
+}
+
+type ReviewReply struct {
+	AuthorID  string     `bson:"authorId" json:"authorId"`
+	Comment   string     `bson:"comment" json:"comment"`
+	CreatedAt time.Time  `bson:"createdAt" json:"createdAt"`
+	EditedAt  *time.Time `bson:"editedAt,omitempty" json:"editedAt,omitempty"`
+}
+
+// ReviewUpdate lists the review fields its author may change; nil fields are left untouched
+type ReviewUpdate struct {
+	Rating  *int      `json:"rating,omitempty"`
+	Comment *string   `json:"comment,omitempty"`
+	Images  *[]string `json:"images,omitempty"`
+}
+
+// ReviewVote is one user's helpfulness vote on a review
+type ReviewVote struct {
+	ReviewID  primitive.ObjectID `bson:"reviewId" json:"reviewId"`
+	UserID    string             `bson:"userId" json:"userId"`
+	Helpful   bool               `bson:"helpful" json:"helpful"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+const (
+	ReviewSortNewest  = "newest"
+	ReviewSortHelpful = "helpful"
+	ReviewSortRating  = "rating"
+)
+
+// ReviewQuery selects a page of a tour's reviews; Page starts at 1
+type ReviewQuery struct {
+	Sort  string
+	Page  int
+	Limit int
 }
@@ -6,6 +6,31 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+type TourStatus string
+
+const (
+	TourDraft     TourStatus = "draft"
+	TourPublished TourStatus = "published"
+	TourArchived  TourStatus = "archived"
+)
+
+// tourTransitions lists the allowed lifecycle moves: draft -> published <-> archived
+var tourTransitions = map[TourStatus][]TourStatus{
+	TourDraft:     {TourPublished},
+	TourPublished: {TourArchived},
+	TourArchived:  {TourPublished},
+}
+
+// CanTransitionTo reports whether a tour in status s may move to status to
+func (s TourStatus) CanTransitionTo(to TourStatus) bool {
+	for _, next := range tourTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
 type TransportDuration struct {
 	Walking int `bson:"walking" json:"walking"` // minutes
 	Biking  int `bson:"biking" json:"biking"`   // minutes
@@ -19,11 +44,70 @@ type Tour struct {
 	Description string             `bson:"description" json:"description"`
 	Difficulty  string             `bson:"difficulty" json:"difficulty"`
 	Tags        []string           `bson:"tags" json:"tags"`
-	Status      string             `bson:"status" json:"status"` // draft, published, archived
+	Status      TourStatus         `bson:"status" json:"status"`
 	Price       float64            `bson:"price" json:"price"`
 	Distance    float64            `bson:"distance" json:"distance"` // kilometers
 	Durations   TransportDuration  `bson:"durations" json:"durations"`
 	PublishedAt *time.Time         `bson:"publishedAt,omitempty" json:"publishedAt,omitempty"`
 	ArchivedAt  *time.Time         `bson:"archivedAt,omitempty" json:"archivedAt,omitempty"`
 	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+
+	// DurationsOverride is set when the guide entered durations by hand; they are then kept
+	// instead of being re-estimated from the key point route
+	DurationsOverride bool `bson:"durationsOverride" json:"durationsOverride"`
+	// Rating aggregates the tour's reviews and is maintained together with them
+	Rating RatingSummary `bson:"rating" json:"rating"`
+	// Forkable lets other users clone the tour; ForkedFrom points to the tour this one was cloned from
+	Forkable   bool                `bson:"forkable" json:"forkable"`
+	ForkedFrom *primitive.ObjectID `bson:"forkedFrom,omitempty" json:"forkedFrom,omitempty"`
+	// SequentialKeyPoints makes tourists reach the key points in their order instead of in any order
+	SequentialKeyPoints bool `bson:"sequentialKeyPoints" json:"sequentialKeyPoints"`
+	// CurrentVersion is the number of the latest published snapshot, 0 until the first publish
+	CurrentVersion int `bson:"currentVersion" json:"currentVersion"`
+	// PendingRevision holds edits made to a published tour that are not live yet
+	PendingRevision *TourRevision `bson:"pendingRevision,omitempty" json:"-"`
+}
+
+// TourRevision is a set of edits to a published tour; nil fields are left unchanged when it is applied
+type TourRevision struct {
+	Name                *string            `bson:"name,omitempty" json:"name,omitempty"`
+	Description         *string            `bson:"description,omitempty" json:"description,omitempty"`
+	Difficulty          *string            `bson:"difficulty,omitempty" json:"difficulty,omitempty"`
+	Tags                []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	Price               *float64           `bson:"price,omitempty" json:"price,omitempty"`
+	Durations           *TransportDuration `bson:"durations,omitempty" json:"durations,omitempty"`
+	DurationsOverride   *bool              `bson:"durationsOverride,omitempty" json:"durationsOverride,omitempty"`
+	SequentialKeyPoints *bool              `bson:"sequentialKeyPoints,omitempty" json:"sequentialKeyPoints,omitempty"`
+	// KeyPoints, staged once KeyPointsChanged is set, replace the live key points and route on publish
+	KeyPointsChanged bool       `bson:"keyPointsChanged,omitempty" json:"keyPointsChanged,omitempty"`
+	KeyPoints        []KeyPoint `bson:"keyPoints,omitempty" json:"keyPoints,omitempty"`
+	UpdatedAt        time.Time  `bson:"updatedAt" json:"updatedAt"`
+}
+
+// ApplyTo copies the revised fields onto t
+func (rev *TourRevision) ApplyTo(t *Tour) {
+	if rev.Name != nil {
+		t.Name = *rev.Name
+	}
+	if rev.Description != nil {
+		t.Description = *rev.Description
+	}
+	if rev.Difficulty != nil {
+		t.Difficulty = *rev.Difficulty
+	}
+	if rev.Tags != nil {
+		t.Tags = rev.Tags
+	}
+	if rev.Price != nil {
+		t.Price = *rev.Price
+	}
+	if rev.Durations != nil {
+		t.Durations = *rev.Durations
+	}
+	if rev.DurationsOverride != nil {
+		t.DurationsOverride = *rev.DurationsOverride
+	}
+	if rev.SequentialKeyPoints != nil {
+		t.SequentialKeyPoints = *rev.SequentialKeyPoints
+	}
 }
@@ -10,20 +10,94 @@ type ExecutionStatus string
 
 const (
 	ExecutionActive    ExecutionStatus = "active"
+	ExecutionPaused    ExecutionStatus = "paused"
 	ExecutionCompleted ExecutionStatus = "completed"
 	ExecutionAbandoned ExecutionStatus = "abandoned"
 )
 
+// PauseInterval is a break the tourist took; End is unset while the execution is paused
+type PauseInterval struct {
+	Start time.Time  `bson:"start" json:"start"`
+	End   *time.Time `bson:"end,omitempty" json:"end,omitempty"`
+}
+
+// TransportMode is how the tourist travels the tour; it bounds how fast they can plausibly move
+type TransportMode string
+
+const (
+	TransportWalking TransportMode = "walking"
+	TransportBiking  TransportMode = "biking"
+	TransportDriving TransportMode = "driving"
+)
+
+func (m TransportMode) Valid() bool {
+	return m == TransportWalking || m == TransportBiking || m == TransportDriving
+}
+
 type TourExecution struct {
 	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	TourID          primitive.ObjectID `bson:"tourId" json:"tourId"`
 	TouristID       string             `bson:"touristId" json:"touristId"`
+	TourVersion     int                `bson:"tourVersion" json:"tourVersion"` // published version the execution follows, 0 for the live key points
 	StartedAt       time.Time          `bson:"startedAt" json:"startedAt"`
 	FinishedAt      *time.Time         `bson:"finishedAt,omitempty" json:"finishedAt,omitempty"`
 	Status          ExecutionStatus    `bson:"status" json:"status"`
 	LastActivity    time.Time          `bson:"lastActivity" json:"lastActivity"`
 	CompletedPoints []CompletedPoint   `bson:"completedPoints" json:"completedPoints"`
-	Locations       []Location         `bson:"locations,omitempty" json:"locations,omitempty"` // where is the tourist during the tour
+	// Locations is the track of the tour. It is kept in its own collection and filled in only where the
+	// whole track is shown; executions recorded before that still carry theirs embedded.
+	Locations     []Location    `bson:"locations,omitempty" json:"locations,omitempty"`
+	LastLocation  *Location     `bson:"lastLocation,omitempty" json:"lastLocation,omitempty"` // newest plausible position
+	TransportMode TransportMode `bson:"transportMode,omitempty" json:"transportMode,omitempty"`
+	// GroupID links the executions of the members of a guided group session
+	GroupID *primitive.ObjectID `bson:"groupId,omitempty" json:"groupId,omitempty"`
+	// Preview executions of a tour the tourist did not buy cover only its first key point and are never completed
+	Preview bool `bson:"preview,omitempty" json:"preview,omitempty"`
+	// SequentialKeyPoints is copied from the tour when the execution starts
+	SequentialKeyPoints bool `bson:"sequentialKeyPoints,omitempty" json:"sequentialKeyPoints,omitempty"`
+	// Suspicious is set once a fix moved implausibly; the guide sees such executions with their anomalies
+	Suspicious bool              `bson:"suspicious,omitempty" json:"suspicious,omitempty"`
+	Anomalies  []MovementAnomaly `bson:"anomalies,omitempty" json:"anomalies,omitempty"`
+	// Share is set while the tourist lets others watch the execution
+	Share *ExecutionShare `bson:"share,omitempty" json:"share,omitempty"`
+	// Pauses are left out of the execution's duration
+	Pauses []PauseInterval `bson:"pauses,omitempty" json:"pauses,omitempty"`
+	// Summary is computed when the execution is completed
+	Summary *ExecutionSummary `bson:"summary,omitempty" json:"summary,omitempty"`
+}
+
+// ExecutionSummary sums up a completed execution from its believable fixes
+type ExecutionSummary struct {
+	DurationSeconds int64   `bson:"durationSeconds" json:"durationSeconds"` // time on the tour without pauses
+	PausedSeconds   int64   `bson:"pausedSeconds,omitempty" json:"pausedSeconds,omitempty"`
+	DistanceMeters  float64 `bson:"distanceMeters" json:"distanceMeters"`
+	AvgPaceMinPerKm float64 `bson:"avgPaceMinPerKm,omitempty" json:"avgPaceMinPerKm,omitempty"` // unset when no distance was covered
+}
+
+// ExecutionQuery selects a page of a tourist's executions, newest first; zero fields match any
+// status or tour and Page starts at 1
+type ExecutionQuery struct {
+	Status ExecutionStatus
+	TourID primitive.ObjectID
+	Page   int
+	Limit  int
+}
+
+type MovementAnomalyType string
+
+const (
+	AnomalyTeleport  MovementAnomalyType = "teleport"
+	AnomalyOverSpeed MovementAnomalyType = "impossible_speed"
+)
+
+// MovementAnomaly describes a fix that could not have been reached from the previous one
+type MovementAnomaly struct {
+	Type      MovementAnomalyType `bson:"type" json:"type"`
+	At        time.Time           `bson:"at" json:"at"`             // timestamp of the offending fix
+	Distance  float64             `bson:"distance" json:"distance"` // meters from the previous plausible fix
+	SpeedKmh  float64             `bson:"speedKmh" json:"speedKmh"`
+	Latitude  float64             `bson:"latitude" json:"latitude"`
+	Longitude float64             `bson:"longitude" json:"longitude"`
 }
 
 type CompletedPoint struct {
@@ -34,5 +108,31 @@ type CompletedPoint struct {
 type Location struct {
 	Latitude  float64   `bson:"latitude" json:"latitude"`
 	Longitude float64   `bson:"longitude" json:"longitude"`
-	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`                   // when the position was recorded on the device
+	Accuracy  *float64  `bson:"accuracy,omitempty" json:"accuracy,omitempty"` // radius in meters reported by the device
+	Flagged   bool      `bson:"flagged,omitempty" json:"flagged,omitempty"`   // implausible fix, never completes key points
+}
+
+type ExecutionEventType string
+
+const (
+	EventKeyPointReached ExecutionEventType = "keypoint_reached"
+	EventProgress        ExecutionEventType = "progress"
+	EventOffRoute        ExecutionEventType = "off_route"
+	EventCompleted       ExecutionEventType = "completed"
+	EventError           ExecutionEventType = "error"
+)
+
+// ExecutionEvent is pushed to the tourist while an execution is being tracked. Distances are in meters.
+type ExecutionEvent struct {
+	Type              ExecutionEventType `json:"type"`
+	ExecutionID       primitive.ObjectID `json:"executionId"`
+	KeyPoint          *KeyPoint          `json:"keyPoint,omitempty"`     // the key point just reached
+	Progress          float64            `json:"progress"`               // percentage of key points completed
+	NextKeyPoint      *KeyPoint          `json:"nextKeyPoint,omitempty"` // first key point not yet reached, in tour order
+	DistanceToNext    *float64           `json:"distanceToNext,omitempty"`
+	DistanceFromRoute *float64           `json:"distanceFromRoute,omitempty"`
+	Summary           *ExecutionSummary  `json:"summary,omitempty"` // set on the completed event
+	Message           string             `json:"message,omitempty"`
+	Timestamp         time.Time          `json:"timestamp"`
 }
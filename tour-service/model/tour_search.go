@@ -0,0 +1,38 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// TourSearchFilter describes a public tour discovery query. Nil bounds are ignored.
+type TourSearchFilter struct {
+	Difficulties []string
+	Tags         []string
+	MatchAllTags bool // true: tour must have every tag, false: any of them
+	MinPrice     *float64
+	MaxPrice     *float64
+	MinDistance  *float64 // kilometers
+	MaxDistance  *float64 // kilometers
+	Transport    string   // walking, biking or driving - selects the duration used below
+	MinDuration  *int     // minutes
+	MaxDuration  *int     // minutes
+	Sort         string
+	Cursor       string
+	Limit        int
+}
+
+type TourSearchResult struct {
+	Tours      []Tour `json:"tours"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// nearby search modes
+const (
+	NearbyStart = "start" // distance to the tour's first key point
+	NearbyAny   = "any"   // distance to the closest of the tour's key points
+)
+
+type NearbyTour struct {
+	Tour       Tour               `bson:"tour" json:"tour"`
+	Distance   float64            `bson:"distance" json:"distance"` // meters
+	KeyPointID primitive.ObjectID `bson:"keyPointId" json:"keyPointId"`
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TourVersion is an immutable snapshot of a tour and its ordered key points, taken each time it is published
+type TourVersion struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TourID      primitive.ObjectID `bson:"tourId" json:"tourId"`
+	Version     int                `bson:"version" json:"version"`
+	Tour        Tour               `bson:"tour" json:"tour"`
+	KeyPoints   []KeyPoint         `bson:"keyPoints" json:"keyPoints,omitempty"`
+	PublishedAt time.Time          `bson:"publishedAt" json:"publishedAt"`
+	// RestoredFrom is set when the version was created by rolling back to an older one
+	RestoredFrom int `bson:"restoredFrom,omitempty" json:"restoredFrom,omitempty"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type KeyPointChange struct {
+	KeyPointID primitive.ObjectID `json:"keyPointId"`
+	Name       string             `json:"name"`
+	Changes    []FieldChange      `json:"changes"`
+}
+
+// TourVersionDiff lists what changed between two versions of a tour
+type TourVersionDiff struct {
+	From             int              `json:"from"`
+	To               int              `json:"to"`
+	Fields           []FieldChange    `json:"fields"`
+	AddedKeyPoints   []KeyPoint       `json:"addedKeyPoints"`
+	RemovedKeyPoints []KeyPoint       `json:"removedKeyPoints"`
+	ChangedKeyPoints []KeyPointChange `json:"changedKeyPoints"`
+	// Reordered is set when the key points present in both versions are visited in a different order
+	Reordered bool `json:"reordered"`
+}
+
+// DiffTourVersions compares the tour fields and key points of two versions
+func DiffTourVersions(from, to *TourVersion) TourVersionDiff {
+	d := TourVersionDiff{
+		From:             from.Version,
+		To:               to.Version,
+		Fields:           []FieldChange{},
+		AddedKeyPoints:   []KeyPoint{},
+		RemovedKeyPoints: []KeyPoint{},
+		ChangedKeyPoints: []KeyPointChange{},
+	}
+	a, b := from.Tour, to.Tour
+	d.Fields = appendChange(d.Fields, "name", a.Name, b.Name)
+	d.Fields = appendChange(d.Fields, "description", a.Description, b.Description)
+	d.Fields = appendChange(d.Fields, "difficulty", a.Difficulty, b.Difficulty)
+	d.Fields = appendChange(d.Fields, "tags", a.Tags, b.Tags)
+	d.Fields = appendChange(d.Fields, "price", a.Price, b.Price)
+	d.Fields = appendChange(d.Fields, "distance", a.Distance, b.Distance)
+	d.Fields = appendChange(d.Fields, "durations", a.Durations, b.Durations)
+	d.Fields = appendChange(d.Fields, "sequentialKeyPoints", a.SequentialKeyPoints, b.SequentialKeyPoints)
+
+	old := make(map[primitive.ObjectID]KeyPoint, len(from.KeyPoints))
+	for _, kp := range from.KeyPoints {
+		old[kp.ID] = kp
+	}
+	kept := make(map[primitive.ObjectID]bool, len(to.KeyPoints))
+	var keptOrder []primitive.ObjectID
+	for _, kp := range to.KeyPoints {
+		prev, ok := old[kp.ID]
+		if !ok {
+			d.AddedKeyPoints = append(d.AddedKeyPoints, kp)
+			continue
+		}
+		kept[kp.ID] = true
+		keptOrder = append(keptOrder, kp.ID)
+		var changes []FieldChange
+		changes = appendChange(changes, "name", prev.Name, kp.Name)
+		changes = appendChange(changes, "description", prev.Description, kp.Description)
+		changes = appendChange(changes, "imageUrl", prev.ImageURL, kp.ImageURL)
+		changes = appendChange(changes, "latitude", prev.Latitude, kp.Latitude)
+		changes = appendChange(changes, "longitude", prev.Longitude, kp.Longitude)
+		changes = appendChange(changes, "radius", prev.Radius, kp.Radius)
+		changes = appendChange(changes, "geofence", prev.Geofence, kp.Geofence)
+		if len(changes) > 0 {
+			d.ChangedKeyPoints = append(d.ChangedKeyPoints, KeyPointChange{KeyPointID: kp.ID, Name: kp.Name, Changes: changes})
+		}
+	}
+	i := 0
+	for _, kp := range from.KeyPoints {
+		if !kept[kp.ID] {
+			d.RemovedKeyPoints = append(d.RemovedKeyPoints, kp)
+			continue
+		}
+		if keptOrder[i] != kp.ID {
+			d.Reordered = true
+		}
+		i++
+	}
+	return d
+}
+
+func appendChange(changes []FieldChange, field string, from, to interface{}) []FieldChange {
+	if reflect.DeepEqual(from, to) {
+		return changes
+	}
+	return append(changes, FieldChange{Field: field, From: from, To: to})
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"tour-service/model"
+)
+
+var (
+	ErrTourNotFound     = errors.New("tour not found")
+	ErrKeyPointNotFound = errors.New("keypoint not found")
+	ErrForbidden        = errors.New("forbidden")
+
+	ErrInvalidTransition  = errors.New("invalid tour status transition")
+	ErrStatusNotEditable  = errors.New("status can only be changed by publishing, archiving or activating the tour")
+	ErrNoPendingRevision  = errors.New("tour has no pending revision")
+	ErrConcurrentTourEdit = errors.New("tour was modified concurrently, retry")
+	ErrVersionNotFound    = errors.New("tour version not found")
+
+	ErrReviewNotFound  = errors.New("review not found")
+	ErrOwnReview       = errors.New("not allowed on your own review")
+	ErrAlreadyReported = errors.New("you have already reported this review")
+
+	ErrExecutionNotFound      = errors.New("execution not found")
+	ErrExecutionNotActive     = errors.New("execution is not active")
+	ErrExecutionNotPaused     = errors.New("execution is not paused")
+	ErrActiveExecutionExists  = errors.New("tourist already has an active tour execution")
+	ErrKeyPointAlreadyReached = errors.New("key point already reached in this execution")
+
+	ErrGroupNotFound = errors.New("group session not found")
+	ErrGroupClosed   = errors.New("group session is closed")
+	ErrGroupFull     = errors.New("group session is full")
+)
+
+// TransitionError reports a lifecycle move the tour state machine does not allow.
+// It matches ErrInvalidTransition with errors.Is.
+type TransitionError struct {
+	From model.TourStatus
+	To   model.TourStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot move tour from %s to %s", e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
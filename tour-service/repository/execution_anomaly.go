@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FlagExecution records movement anomalies and marks the execution suspicious
+func (r *TourRepository) FlagExecution(ctx context.Context, execId primitive.ObjectID, anomalies []model.MovementAnomaly) error {
+	if len(anomalies) == 0 {
+		return nil
+	}
+	_, err := r.execCol.UpdateOne(ctx, bson.M{"_id": execId}, bson.M{
+		"$push": bson.M{"anomalies": bson.M{"$each": anomalies}},
+		"$set":  bson.M{"suspicious": true},
+	})
+	return err
+}
+
+// GetSuspiciousExecutions lists a tour's executions with movement anomalies, most recently active
+// first. Tracks are left out; the anomalies carry the offending positions.
+func (r *TourRepository) GetSuspiciousExecutions(ctx context.Context, tourId primitive.ObjectID) ([]model.TourExecution, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "lastActivity", Value: -1}}).
+		SetProjection(bson.M{"locations": 0})
+	cur, err := r.execCol.Find(ctx, bson.M{"tourId": tourId, "suspicious": true}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	execs := []model.TourExecution{}
+	if err := cur.All(ctx, &execs); err != nil {
+		return nil, err
+	}
+	return execs, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ListExecutions returns one page of a tourist's executions, most recently started first, and the
+// total number matching the query. Tracks are left out of the listing.
+func (r *TourRepository) ListExecutions(ctx context.Context, touristId string, q model.ExecutionQuery) ([]model.TourExecution, int64, error) {
+	filter := bson.M{"touristId": touristId}
+	if q.Status != "" {
+		filter["status"] = q.Status
+	}
+	if !q.TourID.IsZero() {
+		filter["tourId"] = q.TourID
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+
+	total, err := r.execCol.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "startedAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"locations": 0})
+	cur, err := r.execCol.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cur.Close(ctx)
+
+	execs := []model.TourExecution{}
+	if err := cur.All(ctx, &execs); err != nil {
+		return nil, 0, err
+	}
+	return execs, total, nil
+}
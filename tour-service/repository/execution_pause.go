@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PauseExecution moves an active execution to paused and opens a pause interval at its start
+func (r *TourRepository) PauseExecution(ctx context.Context, execId primitive.ObjectID, pause model.PauseInterval) error {
+	if execId.IsZero() {
+		return mongo.ErrNilDocument
+	}
+	res, err := r.execCol.UpdateOne(ctx,
+		bson.M{"_id": execId, "status": model.ExecutionActive},
+		bson.M{
+			"$set":  bson.M{"status": model.ExecutionPaused, "lastActivity": pause.Start},
+			"$push": bson.M{"pauses": pause},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrExecutionNotActive
+	}
+	return nil
+}
+
+// ResumeExecution moves a paused execution back to active and closes its open pause interval at resumedAt
+func (r *TourRepository) ResumeExecution(ctx context.Context, execId primitive.ObjectID, resumedAt time.Time) error {
+	if execId.IsZero() {
+		return mongo.ErrNilDocument
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"open.end": bson.M{"$exists": false}}},
+	})
+	res, err := r.execCol.UpdateOne(ctx,
+		bson.M{"_id": execId, "status": model.ExecutionPaused},
+		bson.M{"$set": bson.M{
+			"status":             model.ExecutionActive,
+			"lastActivity":       resumedAt,
+			"pauses.$[open].end": resumedAt,
+		}},
+		opts,
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrExecutionNotPaused
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// inProgress matches executions that can still be shared
+var inProgress = bson.M{"$in": bson.A{model.ExecutionActive, model.ExecutionPaused}}
+
+// ShareExecution starts sharing an execution in progress, replacing any earlier share and its token
+func (r *TourRepository) ShareExecution(ctx context.Context, execId primitive.ObjectID, share model.ExecutionShare) error {
+	res, err := r.execCol.UpdateOne(ctx,
+		bson.M{"_id": execId, "status": inProgress},
+		bson.M{"$set": bson.M{"share": share}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrExecutionNotActive
+	}
+	return nil
+}
+
+// RevokeExecutionShare stops sharing an execution; its token no longer opens it
+func (r *TourRepository) RevokeExecutionShare(ctx context.Context, execId primitive.ObjectID) error {
+	_, err := r.execCol.UpdateOne(ctx, bson.M{"_id": execId}, bson.M{"$unset": bson.M{"share": ""}})
+	return err
+}
+
+// GetSharedExecution finds the execution in progress a share token hash opens. Expired, revoked
+// and finished shares all read as ErrExecutionNotFound.
+func (r *TourRepository) GetSharedExecution(ctx context.Context, tokenHash string) (*model.TourExecution, error) {
+	filter := bson.M{
+		"share.tokenHash": tokenHash,
+		"share.expiresAt": bson.M{"$gt": time.Now().UTC()},
+		"status":          inProgress,
+	}
+	var exec model.TourExecution
+	err := r.execCol.FindOne(ctx, filter).Decode(&exec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrExecutionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &exec, nil
+}
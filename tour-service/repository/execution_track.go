@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// executionTracksCollection is a time-series collection with one document per stored fix, keyed by
+// execution, so a long tour no longer grows its execution document towards MongoDB's size limit
+const executionTracksCollection = "executionTracks"
+
+type trackPoint struct {
+	ExecutionID    primitive.ObjectID `bson:"executionId"`
+	model.Location `bson:",inline"`
+}
+
+func createTrackCollection(ctx context.Context, db *mongo.Database) *mongo.Collection {
+	// fails harmlessly when the collection already exists
+	_ = db.CreateCollection(ctx, executionTracksCollection, options.CreateCollection().SetTimeSeriesOptions(
+		options.TimeSeries().SetTimeField("timestamp").SetMetaField("executionId").SetGranularity("seconds"),
+	))
+	col := db.Collection(executionTracksCollection)
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "executionId", Value: 1}, {Key: "timestamp", Value: 1}},
+	})
+	return col
+}
+
+// AddLocations stores positions in an execution's track and makes latest its last known position,
+// unless a newer one is already recorded. Either may be empty, as positions are downsampled before
+// they are stored while the last known position is always the newest plausible fix.
+func (r *TourRepository) AddLocations(ctx context.Context, execId primitive.ObjectID, locs []model.Location, latest *model.Location) error {
+	if execId.IsZero() {
+		return mongo.ErrNilDocument
+	}
+
+	now := time.Now().UTC()
+	if len(locs) > 0 {
+		docs := make([]interface{}, len(locs))
+		for i := range locs {
+			// If timestamp is missing, set now
+			if locs[i].Timestamp.IsZero() {
+				locs[i].Timestamp = now
+			}
+			docs[i] = trackPoint{ExecutionID: execId, Location: locs[i]}
+		}
+		if _, err := r.trackCol.InsertMany(ctx, docs); err != nil {
+			return err
+		}
+	}
+
+	set := bson.M{"lastActivity": now}
+	if latest != nil {
+		set["lastLocation"] = bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{latest.Timestamp, bson.M{"$ifNull": bson.A{"$lastLocation.timestamp", time.Time{}}}}},
+			bson.M{"$literal": latest},
+			"$lastLocation",
+		}}
+	}
+	_, err := r.execCol.UpdateOne(ctx, bson.M{"_id": execId}, mongo.Pipeline{{{Key: "$set", Value: set}}})
+	return err
+}
+
+// GetExecutionTrack returns every stored position of an execution in timestamp order, including
+// the ones embedded in executions recorded before tracks had their own collection
+func (r *TourRepository) GetExecutionTrack(ctx context.Context, exec *model.TourExecution) ([]model.Location, error) {
+	return r.findTrack(ctx, bson.M{"executionId": exec.ID}, exec.Locations)
+}
+
+// GetRecentTrack returns the positions of an execution recorded at or after from, preceded by the
+// last plausible one before it, which is what screening and completing new positions compare against
+func (r *TourRepository) GetRecentTrack(ctx context.Context, exec *model.TourExecution, from time.Time) ([]model.Location, error) {
+	var legacy []model.Location
+	var before *model.Location
+	for i := range exec.Locations {
+		loc := exec.Locations[i]
+		if !loc.Timestamp.Before(from) {
+			legacy = append(legacy, loc)
+		} else if !loc.Flagged && (before == nil || loc.Timestamp.After(before.Timestamp)) {
+			before = &loc
+		}
+	}
+
+	var prev trackPoint
+	err := r.trackCol.FindOne(ctx,
+		bson.M{"executionId": exec.ID, "timestamp": bson.M{"$lt": from}, "flagged": bson.M{"$ne": true}},
+		options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}}),
+	).Decode(&prev)
+	switch {
+	case err == nil:
+		if before == nil || prev.Timestamp.After(before.Timestamp) {
+			before = &prev.Location
+		}
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return nil, err
+	}
+	if before != nil {
+		legacy = append(legacy, *before)
+	}
+
+	return r.findTrack(ctx, bson.M{"executionId": exec.ID, "timestamp": bson.M{"$gte": from}}, legacy)
+}
+
+// findTrack reads the stored positions matching filter and merges them with extra in timestamp order
+func (r *TourRepository) findTrack(ctx context.Context, filter bson.M, extra []model.Location) ([]model.Location, error) {
+	cur, err := r.trackCol.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	locs := append([]model.Location{}, extra...)
+	for cur.Next(ctx) {
+		var p trackPoint
+		if err := cur.Decode(&p); err != nil {
+			return nil, err
+		}
+		locs = append(locs, p.Location)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	if len(extra) > 0 {
+		sort.SliceStable(locs, func(i, j int) bool { return locs[i].Timestamp.Before(locs[j].Timestamp) })
+	}
+	return locs, nil
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"time"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	joinCodeLength = 6
+	// no 0/O or 1/I, so codes read out loud or off a screen are not mistyped
+	joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+func createGroupCollection(ctx context.Context, db *mongo.Database) *mongo.Collection {
+	col := db.Collection("groupSessions")
+	// a code identifies one open session; closed sessions free theirs
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "joinCode", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": model.GroupSessionOpen}),
+	})
+	return col
+}
+
+func newJoinCode() (string, error) {
+	code := make([]byte, joinCodeLength)
+	max := big.NewInt(int64(len(joinCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = joinCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// CreateGroupSession opens a session under a fresh join code
+func (r *TourRepository) CreateGroupSession(ctx context.Context, g *model.GroupSession) (*model.GroupSession, error) {
+	if g == nil {
+		return nil, mongo.ErrNilDocument
+	}
+	g.ID = primitive.NewObjectID()
+	g.Status = model.GroupSessionOpen
+	g.CreatedAt = time.Now().UTC()
+
+	// retry the rare code already taken by another open session
+	for attempt := 0; ; attempt++ {
+		code, err := newJoinCode()
+		if err != nil {
+			return nil, err
+		}
+		g.JoinCode = code
+		_, err = r.groupCol.InsertOne(ctx, g)
+		if err == nil {
+			return g, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) || attempt == 4 {
+			return nil, err
+		}
+	}
+}
+
+func (r *TourRepository) GetGroupSession(ctx context.Context, id primitive.ObjectID) (*model.GroupSession, error) {
+	var g model.GroupSession
+	err := r.groupCol.FindOne(ctx, bson.M{"_id": id}).Decode(&g)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// JoinGroupSession takes a seat in the unexpired open session a join code belongs to and returns
+// the session. It fails with ErrGroupFull once every seat is taken.
+func (r *TourRepository) JoinGroupSession(ctx context.Context, code string) (*model.GroupSession, error) {
+	open := bson.M{"joinCode": code, "status": model.GroupSessionOpen, "expiresAt": bson.M{"$gt": time.Now().UTC()}}
+	seat := bson.M{"$expr": bson.M{"$lt": bson.A{"$joined", "$maxMembers"}}}
+	for k, v := range open {
+		seat[k] = v
+	}
+
+	var g model.GroupSession
+	err := r.groupCol.FindOneAndUpdate(ctx, seat,
+		bson.M{"$inc": bson.M{"joined": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&g)
+	if err == nil {
+		return &g, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	n, err := r.groupCol.CountDocuments(ctx, open)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return nil, ErrGroupFull
+	}
+	return nil, ErrGroupNotFound
+}
+
+// LeaveGroupSession frees a seat taken by a tourist whose execution could not be started
+func (r *TourRepository) LeaveGroupSession(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.groupCol.UpdateOne(ctx, bson.M{"_id": id, "joined": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"joined": -1}})
+	return err
+}
+
+// CloseGroupSession stops a session from taking new members; executions already started carry on
+func (r *TourRepository) CloseGroupSession(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.groupCol.UpdateOne(ctx,
+		bson.M{"_id": id, "status": model.GroupSessionOpen},
+		bson.M{"$set": bson.M{"status": model.GroupSessionClosed, "closedAt": time.Now().UTC()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrGroupClosed
+	}
+	return nil
+}
+
+// CloseExpiredGroupSessions closes the open sessions past their expiry, so their join codes stop
+// working and can be handed out again
+func (r *TourRepository) CloseExpiredGroupSessions(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.groupCol.UpdateMany(ctx,
+		bson.M{"status": model.GroupSessionOpen, "expiresAt": bson.M{"$not": bson.M{"$gt": now}}},
+		bson.M{"$set": bson.M{"status": model.GroupSessionClosed, "closedAt": now}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// GetGroupExecutions lists the executions of a session's members in the order they joined, without tracks
+func (r *TourRepository) GetGroupExecutions(ctx context.Context, groupId primitive.ObjectID) ([]model.TourExecution, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "startedAt", Value: 1}}).
+		SetProjection(bson.M{"locations": 0})
+	cur, err := r.execCol.Find(ctx, bson.M{"groupId": groupId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	execs := []model.TourExecution{}
+	if err := cur.All(ctx, &execs); err != nil {
+		return nil, err
+	}
+	return execs, nil
+}
+
+// CompleteGroupPoint records a key point as reached in every active execution of a session that has
+// not reached it yet and returns those executions as they are now
+func (r *TourRepository) CompleteGroupPoint(ctx context.Context, groupId primitive.ObjectID, cp model.CompletedPoint) ([]model.TourExecution, error) {
+	filter := bson.M{
+		"groupId":                    groupId,
+		"status":                     model.ExecutionActive,
+		"completedPoints.keyPointId": bson.M{"$ne": cp.KeyPointID},
+	}
+	var updated []model.TourExecution
+	err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		// the transaction may be retried
+		updated = nil
+		cur, err := r.execCol.Find(sc, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return err
+		}
+		var found []model.TourExecution
+		if err := cur.All(sc, &found); err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return nil
+		}
+		ids := make([]primitive.ObjectID, len(found))
+		for i := range found {
+			ids[i] = found[i].ID
+		}
+
+		_, err = r.execCol.UpdateMany(sc, bson.M{"_id": bson.M{"$in": ids}}, bson.M{
+			"$push": bson.M{"completedPoints": cp},
+			"$set":  bson.M{"lastActivity": time.Now().UTC()},
+		})
+		if err != nil {
+			return err
+		}
+
+		cur, err = r.execCol.Find(sc, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"locations": 0}))
+		if err != nil {
+			return err
+		}
+		return cur.All(sc, &updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (r *TourRepository) GetReviewByID(ctx context.Context, reviewId string) (*model.Review, error) {
+	objID, err := primitive.ObjectIDFromHex(reviewId)
+	if err != nil {
+		return nil, ErrReviewNotFound
+	}
+	var rev model.Review
+	err = r.revCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&rev)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// getOwnedReview loads a review and checks that authorId wrote it
+func (r *TourRepository) getOwnedReview(ctx context.Context, reviewId string, authorId string) (*model.Review, error) {
+	rev, err := r.GetReviewByID(ctx, reviewId)
+	if err != nil {
+		return nil, err
+	}
+	if rev.AuthorID != authorId {
+		return nil, ErrForbidden
+	}
+	return rev, nil
+}
+
+// UpdateReview changes a review on behalf of its author, stamps editedAt and moves the
+// rating aggregate of the tour when the rating changed
+func (r *TourRepository) UpdateReview(ctx context.Context, reviewId string, authorId string, upd model.ReviewUpdate) (*model.Review, error) {
+	var updated model.Review
+	err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		rev, err := r.getOwnedReview(sc, reviewId, authorId)
+		if err != nil {
+			return err
+		}
+		set := bson.M{"editedAt": time.Now().UTC()}
+		if upd.Comment != nil {
+			set["comment"] = *upd.Comment
+		}
+		if upd.Images != nil {
+			set["images"] = *upd.Images
+		}
+		if upd.Rating != nil && *upd.Rating != rev.Rating {
+			set["rating"] = *upd.Rating
+		}
+		// hidden reviews are not part of the aggregate
+		if upd.Rating != nil && *upd.Rating != rev.Rating && !rev.Hidden {
+			if err := r.applyRatingDelta(sc, rev.TourID, rev.Rating, -1); err != nil {
+				return err
+			}
+			if err := r.applyRatingDelta(sc, rev.TourID, *upd.Rating, 1); err != nil {
+				return err
+			}
+		}
+		return r.revCol.FindOneAndUpdate(sc, bson.M{"_id": rev.ID}, bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteReview removes a review with its votes and takes it out of the tour's rating aggregate
+func (r *TourRepository) DeleteReview(ctx context.Context, reviewId string, authorId string) error {
+	return r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		rev, err := r.getOwnedReview(sc, reviewId, authorId)
+		if err != nil {
+			return err
+		}
+		if _, err := r.revCol.DeleteOne(sc, bson.M{"_id": rev.ID}); err != nil {
+			return err
+		}
+		if _, err := r.voteCol.DeleteMany(sc, bson.M{"reviewId": rev.ID}); err != nil {
+			return err
+		}
+		if _, err := r.reportCol.DeleteMany(sc, bson.M{"reviewId": rev.ID}); err != nil {
+			return err
+		}
+		if rev.Hidden {
+			return nil
+		}
+		return r.applyRatingDelta(sc, rev.TourID, rev.Rating, -1)
+	})
+}
+
+// ReplyToReview sets the public reply of the tour's guide, replacing an earlier one
+func (r *TourRepository) ReplyToReview(ctx context.Context, reviewId string, guideId string, comment string) (*model.Review, error) {
+	rev, err := r.GetReviewByID(ctx, reviewId)
+	if err != nil {
+		return nil, err
+	}
+	tour, err := r.GetTourByID(ctx, rev.TourID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if tour.AuthorID != guideId {
+		return nil, ErrForbidden
+	}
+
+	now := time.Now().UTC()
+	reply := model.ReviewReply{AuthorID: guideId, Comment: comment, CreatedAt: now}
+	if rev.Reply != nil {
+		reply.CreatedAt = rev.Reply.CreatedAt
+		reply.EditedAt = &now
+	}
+	var updated model.Review
+	err = r.revCol.FindOneAndUpdate(ctx, bson.M{"_id": rev.ID}, bson.M{"$set": bson.M{"reply": reply}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteReviewReply removes the guide's reply from a review
+func (r *TourRepository) DeleteReviewReply(ctx context.Context, reviewId string, guideId string) error {
+	rev, err := r.GetReviewByID(ctx, reviewId)
+	if err != nil {
+		return err
+	}
+	tour, err := r.GetTourByID(ctx, rev.TourID.Hex())
+	if err != nil {
+		return err
+	}
+	if tour.AuthorID != guideId {
+		return ErrForbidden
+	}
+	_, err = r.revCol.UpdateOne(ctx, bson.M{"_id": rev.ID}, bson.M{"$unset": bson.M{"reply": ""}})
+	return err
+}
+
+// VoteReview records or changes a user's helpfulness vote and keeps the counters on the review in step
+func (r *TourRepository) VoteReview(ctx context.Context, reviewId string, userId string, helpful bool) (*model.Review, error) {
+	var updated model.Review
+	err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		rev, err := r.GetReviewByID(sc, reviewId)
+		if err != nil {
+			return err
+		}
+		if rev.AuthorID == userId {
+			return ErrOwnReview
+		}
+
+		var prev model.ReviewVote
+		err = r.voteCol.FindOneAndUpdate(sc,
+			bson.M{"reviewId": rev.ID, "userId": userId},
+			bson.M{"$set": bson.M{"helpful": helpful, "createdAt": time.Now().UTC()}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+		).Decode(&prev)
+		inc := bson.M{}
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			inc[voteCounter(helpful)] = 1
+		case err != nil:
+			return err
+		case prev.Helpful != helpful:
+			inc[voteCounter(helpful)] = 1
+			inc[voteCounter(prev.Helpful)] = -1
+		}
+		if len(inc) == 0 {
+			// same vote again, nothing to count
+			updated = *rev
+			return nil
+		}
+		return r.revCol.FindOneAndUpdate(sc, bson.M{"_id": rev.ID}, bson.M{"$inc": inc},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// RemoveReviewVote withdraws a user's vote on a review
+func (r *TourRepository) RemoveReviewVote(ctx context.Context, reviewId string, userId string) error {
+	return r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		rev, err := r.GetReviewByID(sc, reviewId)
+		if err != nil {
+			return err
+		}
+		var prev model.ReviewVote
+		err = r.voteCol.FindOneAndDelete(sc, bson.M{"reviewId": rev.ID, "userId": userId}).Decode(&prev)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = r.revCol.UpdateOne(sc, bson.M{"_id": rev.ID}, bson.M{"$inc": bson.M{voteCounter(prev.Helpful): -1}})
+		return err
+	})
+}
+
+func voteCounter(helpful bool) string {
+	if helpful {
+		return "helpfulCount"
+	}
+	return "unhelpfulCount"
+}
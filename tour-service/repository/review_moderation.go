@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultReportThreshold = 3
+
+// ReportReview files a user's report against a review. Once the review has more open reports
+// than the threshold it is hidden, and taken out of the rating aggregate, until an admin decides.
+func (r *TourRepository) ReportReview(ctx context.Context, reviewId string, report model.ReviewReport) (*model.Review, error) {
+	var updated model.Review
+	err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		rev, err := r.GetReviewByID(sc, reviewId)
+		if err != nil {
+			return err
+		}
+		if rev.AuthorID == report.ReporterID {
+			return ErrOwnReview
+		}
+
+		report.ReviewID = rev.ID
+		report.CreatedAt = time.Now().UTC()
+		report.Resolved = false
+		if _, err := r.reportCol.InsertOne(sc, report); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return ErrAlreadyReported
+			}
+			return err
+		}
+		err = r.revCol.FindOneAndUpdate(sc, bson.M{"_id": rev.ID}, bson.M{"$inc": bson.M{"reportCount": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+		if err != nil {
+			return err
+		}
+		if updated.Hidden || updated.ReportCount <= r.reportThreshold {
+			return nil
+		}
+		return r.setReviewHidden(sc, &updated, true, model.HiddenByReports)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// setReviewHidden flips the hidden flag and moves the review out of or back into the rating aggregate.
+// It must run inside a transaction.
+func (r *TourRepository) setReviewHidden(sc mongo.SessionContext, rev *model.Review, hidden bool, reason string) error {
+	if rev.Hidden == hidden {
+		return nil
+	}
+	update := bson.M{"$set": bson.M{"hidden": hidden, "hiddenReason": reason}}
+	delta := 1
+	if hidden {
+		delta = -1
+	} else {
+		update = bson.M{"$set": bson.M{"hidden": false}, "$unset": bson.M{"hiddenReason": ""}}
+	}
+	if _, err := r.revCol.UpdateOne(sc, bson.M{"_id": rev.ID}, update); err != nil {
+		return err
+	}
+	if err := r.applyRatingDelta(sc, rev.TourID, rev.Rating, delta); err != nil {
+		return err
+	}
+	rev.Hidden = hidden
+	rev.HiddenReason = ""
+	if hidden {
+		rev.HiddenReason = reason
+	}
+	return nil
+}
+
+// ModerateReview records an admin decision: the review is hidden or restored and its open reports are resolved
+func (r *TourRepository) ModerateReview(ctx context.Context, reviewId string, adminId string, hide bool) (*model.Review, error) {
+	var updated model.Review
+	err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		rev, err := r.GetReviewByID(sc, reviewId)
+		if err != nil {
+			return err
+		}
+		hiddenByReports := rev.Hidden
+		if err := r.setReviewHidden(sc, rev, hide, model.HiddenByAdmin); err != nil {
+			return err
+		}
+		// the admin's decision replaces the automatic one
+		if hide && hiddenByReports {
+			if _, err := r.revCol.UpdateOne(sc, bson.M{"_id": rev.ID}, bson.M{"$set": bson.M{"hiddenReason": model.HiddenByAdmin}}); err != nil {
+				return err
+			}
+		}
+		now := time.Now().UTC()
+		_, err = r.reportCol.UpdateMany(sc,
+			bson.M{"reviewId": rev.ID, "resolved": false},
+			bson.M{"$set": bson.M{"resolved": true, "resolvedBy": adminId, "resolvedAt": now}},
+		)
+		if err != nil {
+			return err
+		}
+		// resolved reports no longer count towards the automatic threshold
+		return r.revCol.FindOneAndUpdate(sc, bson.M{"_id": rev.ID}, bson.M{"$set": bson.M{"reportCount": 0}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// GetModerationQueue lists reviews with open reports, most reported first
+func (r *TourRepository) GetModerationQueue(ctx context.Context, limit int) ([]model.ModerationItem, error) {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"resolved": false}}},
+		{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     "$reviewId",
+			"reports": bson.M{"$push": "$$ROOT"},
+			"count":   bson.M{"$sum": 1},
+			"first":   bson.M{"$min": "$createdAt"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "first", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$lookup", Value: bson.M{"from": r.revCol.Name(), "localField": "_id", "foreignField": "_id", "as": "review"}}},
+		{{Key: "$unwind", Value: "$review"}},
+		{{Key: "$project", Value: bson.M{"review": 1, "reports": 1}}},
+	}
+	cur, err := r.reportCol.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var found []struct {
+		Review  model.Review         `bson:"review"`
+		Reports []model.ReviewReport `bson:"reports"`
+	}
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	items := make([]model.ModerationItem, len(found))
+	for i := range found {
+		items[i] = model.ModerationItem{Review: model.NewAdminReview(found[i].Review), Reports: found[i].Reports}
+	}
+	return items, nil
+}
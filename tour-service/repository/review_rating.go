@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// withTransaction runs fn in a transaction, retrying on transient errors.
+// Transactions need MongoDB to run as a replica set (see docker-compose.yml).
+func (r *TourRepository) withTransaction(ctx context.Context, fn func(ctx mongo.SessionContext) error) error {
+	sess, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(ctx)
+	_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// applyRatingDelta adds (delta = 1) or removes (delta = -1) a review with the given rating from
+// the tour's aggregate and recomputes the average. Call it in the same transaction as the review write.
+func (r *TourRepository) applyRatingDelta(ctx context.Context, tourId primitive.ObjectID, rating int, delta int) error {
+	if rating < 1 || rating > 5 {
+		return nil
+	}
+	res, err := r.col.UpdateOne(ctx, bson.M{"_id": tourId}, bson.M{"$inc": bson.M{
+		"rating.count": delta,
+		"rating.sum":   delta * rating,
+		"rating.histogram." + strconv.Itoa(rating): delta,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrTourNotFound
+	}
+	_, err = r.col.UpdateOne(ctx, bson.M{"_id": tourId}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"rating.average": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{"$rating.count", 0}},
+			bson.M{"$round": bson.A{bson.M{"$divide": bson.A{"$rating.sum", "$rating.count"}}, 2}},
+			0,
+		}}}}},
+	})
+	return err
+}
+
+// GetTourRatingStats returns the review aggregate of a tour
+func (r *TourRepository) GetTourRatingStats(ctx context.Context, tourId primitive.ObjectID) (*model.RatingSummary, error) {
+	var tour model.Tour
+	opts := options.FindOne().SetProjection(bson.M{"rating": 1})
+	err := r.col.FindOne(ctx, bson.M{"_id": tourId}, opts).Decode(&tour)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrTourNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tour.Rating, nil
+}
+
+// backfillRatings computes the aggregate for tours reviewed before it was maintained
+func backfillRatings(ctx context.Context, col *mongo.Collection, revCol *mongo.Collection) {
+	cur, err := col.Find(ctx, bson.M{"rating": bson.M{"$exists": false}}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return
+	}
+	var missing []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &missing); err != nil || len(missing) == 0 {
+		return
+	}
+	for _, t := range missing {
+		summary := model.RatingSummary{}
+		counts, err := revCol.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"tourId": t.ID, "hidden": bson.M{"$ne": true}}}},
+			{{Key: "$group", Value: bson.M{"_id": "$rating", "n": bson.M{"$sum": 1}}}},
+		})
+		if err != nil {
+			return
+		}
+		var rows []struct {
+			Rating int `bson:"_id"`
+			N      int `bson:"n"`
+		}
+		if err := counts.All(ctx, &rows); err != nil {
+			return
+		}
+		for _, row := range rows {
+			summary.Add(row.Rating, row.N)
+		}
+		_, _ = col.UpdateOne(ctx, bson.M{"_id": t.ID, "rating": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"rating": summary}})
+	}
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tour-service/model"
+	"tour-service/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CloneTour copies a tour and its key points into a new draft owned by userId.
+// The author clones the live tour including unpublished edits; other users may only clone
+// a forkable tour, and get its latest published version.
+func (r *TourRepository) CloneTour(ctx context.Context, tourId string, userId string) (*model.Tour, error) {
+	src, err := r.GetTourByID(ctx, tourId)
+	if errors.Is(err, primitive.ErrInvalidHex) {
+		return nil, ErrTourNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var kps []model.KeyPoint
+	if src.AuthorID == userId {
+		if kps, err = r.editedKeyPoints(ctx, src); err != nil {
+			return nil, err
+		}
+		if rev := src.PendingRevision; rev != nil {
+			rev.ApplyTo(src)
+		}
+	} else {
+		if !src.Forkable || src.CurrentVersion == 0 {
+			return nil, ErrForbidden
+		}
+		v, err := r.GetTourVersion(ctx, src.ID, src.CurrentVersion)
+		if err != nil {
+			return nil, err
+		}
+		src, kps = &v.Tour, v.KeyPoints
+	}
+
+	now := time.Now().UTC()
+	forkedFrom := src.ID
+	clone := &model.Tour{
+		ID:                  primitive.NewObjectID(),
+		AuthorID:            userId,
+		Name:                src.Name,
+		Description:         src.Description,
+		Difficulty:          src.Difficulty,
+		Tags:                append([]string(nil), src.Tags...),
+		Status:              model.TourDraft,
+		Price:               src.Price,
+		CreatedAt:           now,
+		DurationsOverride:   src.DurationsOverride,
+		SequentialKeyPoints: src.SequentialKeyPoints,
+		ForkedFrom:          &forkedFrom,
+	}
+	// the author's staged key points may not follow the live route
+	clone.Distance = utils.RouteDistance(kps)
+	clone.Durations = src.Durations
+	if !clone.DurationsOverride {
+		clone.Durations = r.speeds.Estimate(clone.Distance)
+	}
+	if _, err := r.col.InsertOne(ctx, clone); err != nil {
+		return nil, err
+	}
+
+	if len(kps) > 0 {
+		docs := make([]interface{}, len(kps))
+		for i, kp := range kps {
+			kp.ID = primitive.NewObjectID()
+			kp.TourID = clone.ID
+			kp.CreatedAt = now
+			kp.Location = model.NewGeoPoint(kp.Latitude, kp.Longitude)
+			docs[i] = kp
+		}
+		if _, err := r.kpCol.InsertMany(ctx, docs); err != nil {
+			// don't leave a clone without its route behind
+			_, _ = r.kpCol.DeleteMany(ctx, bson.M{"tourId": clone.ID})
+			_, _ = r.col.DeleteOne(ctx, bson.M{"_id": clone.ID})
+			return nil, err
+		}
+	}
+	return clone, nil
+}
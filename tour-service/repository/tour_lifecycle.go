@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// editableTourFields are the tour fields UpdateTour may change; everything else,
+// status included, is owned by the repository
+var editableTourFields = map[string]bool{
+	"name":                true,
+	"description":         true,
+	"difficulty":          true,
+	"tags":                true,
+	"price":               true,
+	"durations":           true,
+	"durationsOverride":   true,
+	"forkable":            true,
+	"sequentialKeyPoints": true,
+}
+
+// tourSettingsFields are applied to the live tour right away, even once it is published,
+// because they do not change what tourists execute
+var tourSettingsFields = map[string]bool{
+	"forkable": true,
+}
+
+// getOwnedTour loads a tour and checks that authorId wrote it
+func (r *TourRepository) getOwnedTour(ctx context.Context, tourId string, authorId string) (*model.Tour, error) {
+	tour, err := r.GetTourByID(ctx, tourId)
+	if errors.Is(err, primitive.ErrInvalidHex) {
+		return nil, ErrTourNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tour.AuthorID != authorId {
+		return nil, ErrForbidden
+	}
+	return tour, nil
+}
+
+// transitionTour moves a tour from status from to status to if it is in status from and the state
+// machine allows the move. The update is conditional on the status read, so two racing transitions
+// cannot both succeed.
+func (r *TourRepository) transitionTour(ctx context.Context, tourId string, authorId string, from, to model.TourStatus, set bson.M) (*model.Tour, error) {
+	tour, err := r.getOwnedTour(ctx, tourId, authorId)
+	if err != nil {
+		return nil, err
+	}
+	if tour.Status != from || !from.CanTransitionTo(to) {
+		return nil, &TransitionError{From: tour.Status, To: to}
+	}
+	set["status"] = to
+
+	filter := bson.M{"_id": tour.ID, "status": tour.Status}
+	var updated model.Tour
+	err = r.col.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrConcurrentTourEdit
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// PublishTourRevision applies the pending revision of a tour to its live fields and key points,
+// recomputes the route and records the result as a new version
+func (r *TourRepository) PublishTourRevision(ctx context.Context, tourId string, authorId string) (*model.Tour, error) {
+	tour, err := r.getOwnedTour(ctx, tourId, authorId)
+	if err != nil {
+		return nil, err
+	}
+	rev := tour.PendingRevision
+	if rev == nil {
+		return nil, ErrNoPendingRevision
+	}
+	rev.ApplyTo(tour)
+
+	// only apply the revision that was read; a newer edit has to be reviewed again
+	filter := bson.M{"_id": tour.ID, "status": tour.Status, "pendingRevision.updatedAt": rev.UpdatedAt}
+	update := bson.M{
+		"$set": bson.M{
+			"name":                tour.Name,
+			"description":         tour.Description,
+			"difficulty":          tour.Difficulty,
+			"tags":                tour.Tags,
+			"price":               tour.Price,
+			"durations":           tour.Durations,
+			"durationsOverride":   tour.DurationsOverride,
+			"sequentialKeyPoints": tour.SequentialKeyPoints,
+		},
+		"$unset": bson.M{"pendingRevision": ""},
+	}
+	var published *model.Tour
+	err = r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		res, err := r.col.UpdateOne(sc, filter, update)
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return ErrConcurrentTourEdit
+		}
+		if rev.KeyPointsChanged {
+			if err := r.replaceKeyPoints(sc, tour.ID, append([]model.KeyPoint(nil), rev.KeyPoints...)); err != nil {
+				return err
+			}
+		}
+		// staged key points change the distance, and the revision may have switched back to estimated durations
+		live, err := r.RecalculateRoute(sc, tour.ID)
+		if err != nil {
+			return err
+		}
+		published, err = r.snapshotTour(sc, live, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// DiscardTourRevision drops the pending revision of a tour, staged key points included,
+// leaving the live fields and key points untouched
+func (r *TourRepository) DiscardTourRevision(ctx context.Context, tourId string, authorId string) error {
+	tour, err := r.getOwnedTour(ctx, tourId, authorId)
+	if err != nil {
+		return err
+	}
+	res, err := r.col.UpdateOne(ctx,
+		bson.M{"_id": tour.ID, "pendingRevision": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"pendingRevision": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNoPendingRevision
+	}
+	return nil
+}
+
+// editedKeyPoints returns the key points the author of a tour is working on: those staged in its
+// pending revision, otherwise the live ones. The slice is the caller's to modify.
+func (r *TourRepository) editedKeyPoints(ctx context.Context, tour *model.Tour) ([]model.KeyPoint, error) {
+	if rev := tour.PendingRevision; rev != nil && rev.KeyPointsChanged {
+		return append([]model.KeyPoint{}, rev.KeyPoints...), nil
+	}
+	return r.GetKeyPointsByTour(ctx, tour.ID)
+}
+
+// stageKeyPoints keeps the edited key points of a published or archived tour in its pending
+// revision, so tourists go on seeing the live key points and route until the revision is published.
+// Only the revision that was read is replaced; a concurrent edit has to be retried.
+func (r *TourRepository) stageKeyPoints(ctx context.Context, tour *model.Tour, kps []model.KeyPoint) error {
+	sort.SliceStable(kps, func(i, j int) bool { return kps[i].Order < kps[j].Order })
+	if kps == nil {
+		kps = []model.KeyPoint{}
+	}
+	filter := bson.M{"_id": tour.ID, "status": tour.Status}
+	if rev := tour.PendingRevision; rev != nil {
+		filter["pendingRevision.updatedAt"] = rev.UpdatedAt
+	} else {
+		filter["pendingRevision"] = bson.M{"$exists": false}
+	}
+	res, err := r.col.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"pendingRevision.keyPoints":        kps,
+		"pendingRevision.keyPointsChanged": true,
+		"pendingRevision.updatedAt":        time.Now().UTC(),
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrConcurrentTourEdit
+	}
+	return nil
+}
+
+// getStagedKeyPoint finds a key point that so far only exists in a pending revision
+func (r *TourRepository) getStagedKeyPoint(ctx context.Context, id primitive.ObjectID) (*model.KeyPoint, error) {
+	var tour model.Tour
+	err := r.col.FindOne(ctx, bson.M{"pendingRevision.keyPoints._id": id}).Decode(&tour)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrKeyPointNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, kp := range tour.PendingRevision.KeyPoints {
+		if kp.ID == id {
+			return &kp, nil
+		}
+	}
+	return nil, ErrKeyPointNotFound
+}
+
+// replaceKeyPoints swaps the live key points of a tour for the given ones, keeping their ids
+// so completed points recorded against them stay valid
+func (r *TourRepository) replaceKeyPoints(ctx context.Context, tourId primitive.ObjectID, kps []model.KeyPoint) error {
+	if _, err := r.kpCol.DeleteMany(ctx, bson.M{"tourId": tourId}); err != nil {
+		return err
+	}
+	if len(kps) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(kps))
+	for i := range kps {
+		kps[i].Location = model.NewGeoPoint(kps[i].Latitude, kps[i].Longitude)
+		docs[i] = kps[i]
+	}
+	_, err := r.kpCol.InsertMany(ctx, docs)
+	return err
+}
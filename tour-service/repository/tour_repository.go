@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"tour-service/model"
+	"tour-service/utils"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -19,8 +20,16 @@ type TourRepository struct {
 	col       *mongo.Collection
 	kpCol     *mongo.Collection
 	revCol    *mongo.Collection
+	voteCol   *mongo.Collection
+	reportCol *mongo.Collection
 	execCol   *mongo.Collection
+	trackCol  *mongo.Collection
+	groupCol  *mongo.Collection
+	verCol    *mongo.Collection
 	tokensCol *mongo.Collection
+	speeds    utils.TravelSpeeds
+	// reviews with more open reports than this are hidden until an admin decides
+	reportThreshold int
 }
 
 func NewTourRepository(ctx context.Context, uri string, dbName string) (*TourRepository, error) {
@@ -34,7 +43,12 @@ func NewTourRepository(ctx context.Context, uri string, dbName string) (*TourRep
 	col := db.Collection("tours")
 	kpCol := db.Collection("keypoints")
 	revCol := db.Collection("reviews")
+	voteCol := db.Collection("reviewVotes")
+	reportCol := db.Collection("reviewReports")
 	execCol := db.Collection("executions")
+	verCol := db.Collection("tourVersions")
+	trackCol := createTrackCollection(ctx, db)
+	groupCol := createGroupCollection(ctx, db)
 
 	// Access purchases database for checking purchased tours
 	purchaseDB := client.Database("purchases")
@@ -44,9 +58,39 @@ func NewTourRepository(ctx context.Context, uri string, dbName string) (*TourRep
 	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: bson.D{{Key: "authorId", Value: 1}},
 	})
+	// discovery indexes: every public search filters on status and sorts by one of these fields
+	_, _ = col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "publishedAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "price", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "distance", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "durations.walking", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "durations.biking", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "durations.driving", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "rating.average", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "difficulty", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+	})
+	// the newest/oldest cursor is keyed on publishedAt, so tours published before it was recorded
+	// get their creation time
+	_, _ = col.UpdateMany(ctx, bson.M{
+		"status":      bson.M{"$in": bson.A{model.TourPublished, model.TourArchived}},
+		"publishedAt": nil,
+	}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"publishedAt": bson.M{"$ifNull": bson.A{"$createdAt", bson.M{"$toDate": "$_id"}}}}}},
+	})
 	_, _ = kpCol.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: bson.D{{Key: "tourId", Value: 1}},
 	})
+	// backfill GeoJSON locations for key points stored before they existed, then index them
+	_, _ = kpCol.UpdateMany(ctx, bson.M{"location": bson.M{"$exists": false}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"location": bson.M{
+			"type":        "Point",
+			"coordinates": bson.A{"$longitude", "$latitude"},
+		}}}},
+	})
+	_, _ = kpCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "location", Value: "2dsphere"}},
+	})
 	// reviews: index by tourId to lookup reviews for a tour, and by authorId if needed
 	_, _ = revCol.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: bson.D{{Key: "tourId", Value: 1}},
@@ -54,35 +98,88 @@ func NewTourRepository(ctx context.Context, uri string, dbName string) (*TourRep
 	_, _ = revCol.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: bson.D{{Key: "authorId", Value: 1}},
 	})
+	_, _ = revCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tourId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "tourId", Value: 1}, {Key: "helpfulCount", Value: -1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "tourId", Value: 1}, {Key: "rating", Value: -1}, {Key: "createdAt", Value: -1}}},
+	})
+	backfillRatings(ctx, col, revCol)
+	// one helpfulness vote per user and review
+	_, _ = voteCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "reviewId", Value: 1}, {Key: "userId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	// a user reports a review at most once; the moderation queue reads open reports
+	_, _ = reportCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "reviewId", Value: 1}, {Key: "reporterId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "resolved", Value: 1}, {Key: "reviewId", Value: 1}}},
+	})
 	_, _ = execCol.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: bson.D{{Key: "tourId", Value: 1}},
 	})
 	_, _ = execCol.Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: bson.D{{Key: "touristId", Value: 1}},
+		Keys: bson.D{{Key: "touristId", Value: 1}, {Key: "startedAt", Value: -1}},
 	})
 	_, _ = execCol.Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: bson.D{{Key: "status", Value: 1}},
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "lastActivity", Value: 1}},
+	})
+	// shared executions are opened by the hash of their token
+	_, _ = execCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "share.tokenHash", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	// guides follow the executions of their group sessions
+	_, _ = execCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "groupId", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	// guides review the suspicious executions of their tours
+	_, _ = execCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tourId", Value: 1}, {Key: "lastActivity", Value: -1}},
+		Options: options.Index().SetPartialFilterExpression(bson.M{"suspicious": true}),
+	})
+	// one snapshot per tour and version number
+	_, _ = verCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tourId", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
 	})
 	return &TourRepository{
 		client:    client,
 		col:       col,
 		kpCol:     kpCol,
 		revCol:    revCol,
+		voteCol:   voteCol,
+		reportCol: reportCol,
 		execCol:   execCol,
+		trackCol:  trackCol,
+		groupCol:  groupCol,
+		verCol:    verCol,
 		tokensCol: tokensCol,
+		speeds:    utils.DefaultTravelSpeeds(),
+
+		reportThreshold: defaultReportThreshold,
 	}, nil
 }
 
+// SetTravelSpeeds changes the average speeds used to estimate tour durations
+func (r *TourRepository) SetTravelSpeeds(s utils.TravelSpeeds) {
+	r.speeds = s
+}
+
+// SetReportThreshold changes how many open reports a review may have before it is hidden automatically
+func (r *TourRepository) SetReportThreshold(n int) {
+	r.reportThreshold = n
+}
+
 func (r *TourRepository) Close(ctx context.Context) error {
 	return r.client.Disconnect(ctx)
 }
 
 func (r *TourRepository) CreateTour(ctx context.Context, t *model.Tour) (*model.Tour, error) {
 	t.CreatedAt = time.Now().UTC()
-	// ensure defaults on creation
-	if t.Status == "" {
-		t.Status = "draft"
-	}
+	// every tour starts as a draft; publishing goes through PublishTour
+	t.Status = model.TourDraft
+	t.PendingRevision = nil
 	// initial price should be 0
 	t.Price = 0
 	res, err := r.col.InsertOne(ctx, t)
@@ -100,6 +197,9 @@ func (r *TourRepository) GetTourByID(ctx context.Context, tourId string) (*model
 	}
 	var tour model.Tour
 	err = r.col.FindOne(ctx, bson.M{"_id": objID}).Decode(&tour)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrTourNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -124,38 +224,128 @@ func (r *TourRepository) GetToursByAuthor(ctx context.Context, authorId string)
 	return tours, nil
 }
 
-func (r *TourRepository) UpdateTour(ctx context.Context, tourId string, authorId string, updates map[string]interface{}) (*model.Tour, error) {
-	objID, err := primitive.ObjectIDFromHex(tourId)
+// UpdateTour changes the editable fields of a tour. Drafts are edited in place; once a tour
+// has been published, edits are collected in its pending revision so tourists keep seeing
+// the live version until the author publishes the revision. Settings such as forkable are
+// always applied live. staged reports whether this call added anything to the pending revision.
+// The status may be echoed back unchanged but never changed here.
+func (r *TourRepository) UpdateTour(ctx context.Context, tourId string, authorId string, updates map[string]interface{}) (tour *model.Tour, staged bool, err error) {
+	tour, err = r.getOwnedTour(ctx, tourId, authorId)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if status, ok := updates["status"]; ok && status != tour.Status {
+		return nil, false, ErrStatusNotEditable
+	}
+
+	set := bson.M{}
+	prefix := ""
+	if tour.Status != model.TourDraft {
+		prefix = "pendingRevision."
+	}
+	for k, v := range updates {
+		switch {
+		case tourSettingsFields[k]:
+			set[k] = v
+		case editableTourFields[k]:
+			set[prefix+k] = v
+			if prefix != "" {
+				set["pendingRevision.updatedAt"] = time.Now().UTC()
+				staged = true
+			}
+		}
+	}
+	if len(set) == 0 {
+		return tour, false, nil
 	}
-	// Verify ownership
-	filter := bson.M{"_id": objID, "authorId": authorId}
-	// Prevent updating certain fields
-	delete(updates, "_id")
-	delete(updates, "authorId")
-	delete(updates, "createdAt")
 
-	update := bson.M{"$set": updates}
-	var tour model.Tour
-	err = r.col.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&tour)
+	filter := bson.M{"_id": tour.ID, "status": tour.Status}
+	var updated model.Tour
+	err = r.col.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, false, ErrConcurrentTourEdit
+	}
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	return &tour, nil
-} // KeyPoint methods
+	return &updated, staged, nil
+}
+
+// KeyPoint methods. Once a tour is published its key point edits are staged in the pending revision.
+
 func (r *TourRepository) CreateKeyPoint(ctx context.Context, kp *model.KeyPoint) (*model.KeyPoint, error) {
-	kp.CreatedAt = time.Now().UTC()
-	// ensure TourID is set
-	if kp.TourID.IsZero() {
+	created, err := r.CreateKeyPoints(ctx, kp.TourID, []model.KeyPoint{*kp})
+	if err != nil {
+		return nil, err
+	}
+	return &created[0], nil
+}
+
+// CreateKeyPoints bulk-inserts key points for a tour, appending them after the existing ones in the given order
+func (r *TourRepository) CreateKeyPoints(ctx context.Context, tourId primitive.ObjectID, kps []model.KeyPoint) ([]model.KeyPoint, error) {
+	if tourId.IsZero() || len(kps) == 0 {
 		return nil, mongo.ErrNilDocument
 	}
-	res, err := r.kpCol.InsertOne(ctx, kp)
+	tour, err := r.GetTourByID(ctx, tourId.Hex())
+	if err != nil {
+		return nil, err
+	}
+	existing, err := r.editedKeyPoints(ctx, tour)
+	if err != nil {
+		return nil, err
+	}
+	next := nextKeyPointOrder(existing)
+
+	now := time.Now().UTC()
+	for i := range kps {
+		kps[i].ID = primitive.NewObjectID()
+		kps[i].TourID = tourId
+		kps[i].Order = next + i
+		kps[i].CreatedAt = now
+		kps[i].Location = model.NewGeoPoint(kps[i].Latitude, kps[i].Longitude)
+	}
+	if tour.Status != model.TourDraft {
+		if err := r.stageKeyPoints(ctx, tour, append(existing, kps...)); err != nil {
+			return nil, err
+		}
+		return kps, nil
+	}
+	docs := make([]interface{}, len(kps))
+	for i := range kps {
+		docs[i] = kps[i]
+	}
+	if _, err := r.kpCol.InsertMany(ctx, docs); err != nil {
+		return nil, err
+	}
+	return kps, nil
+}
+
+// nextKeyPointOrder returns the order value that places a new key point after all existing ones
+func nextKeyPointOrder(existing []model.KeyPoint) int {
+	next := len(existing)
+	for _, kp := range existing {
+		if kp.Order >= next {
+			next = kp.Order + 1
+		}
+	}
+	return next
+}
+
+// GetKeyPointByID finds a live key point, or one only staged in the pending revision of its tour
+func (r *TourRepository) GetKeyPointByID(ctx context.Context, keypointId string) (*model.KeyPoint, error) {
+	objID, err := primitive.ObjectIDFromHex(keypointId)
+	if err != nil {
+		return nil, ErrKeyPointNotFound
+	}
+	var kp model.KeyPoint
+	err = r.kpCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&kp)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return r.getStagedKeyPoint(ctx, objID)
+	}
 	if err != nil {
 		return nil, err
 	}
-	kp.ID = res.InsertedID.(primitive.ObjectID)
-	return kp, nil
+	return &kp, nil
 }
 
 func (r *TourRepository) GetKeyPointsByTour(ctx context.Context, tourId primitive.ObjectID) ([]model.KeyPoint, error) {
@@ -177,42 +367,171 @@ func (r *TourRepository) GetKeyPointsByTour(ctx context.Context, tourId primitiv
 	return kps, nil
 }
 
-func (r *TourRepository) UpdateKeyPoint(ctx context.Context, keypointId string, updates map[string]interface{}) (*model.KeyPoint, error) {
+// UpdateKeyPoint applies the whitelisted fields to a key point of the given tour
+func (r *TourRepository) UpdateKeyPoint(ctx context.Context, tourId primitive.ObjectID, keypointId string, upd model.KeyPointUpdate) (*model.KeyPoint, error) {
 	objID, err := primitive.ObjectIDFromHex(keypointId)
+	if err != nil {
+		return nil, ErrKeyPointNotFound
+	}
+	tour, err := r.GetTourByID(ctx, tourId.Hex())
 	if err != nil {
 		return nil, err
 	}
-	filter := bson.M{"_id": objID}
-	// Prevent updating certain fields
-	delete(updates, "_id")
-	delete(updates, "tourId")
-	delete(updates, "createdAt")
+	if tour.Status != model.TourDraft {
+		kps, err := r.editedKeyPoints(ctx, tour)
+		if err != nil {
+			return nil, err
+		}
+		for i := range kps {
+			if kps[i].ID == objID {
+				upd.ApplyTo(&kps[i])
+				kp := kps[i]
+				if err := r.stageKeyPoints(ctx, tour, kps); err != nil {
+					return nil, err
+				}
+				return &kp, nil
+			}
+		}
+		return nil, ErrKeyPointNotFound
+	}
+	filter := bson.M{"_id": objID, "tourId": tourId}
+
+	set := bson.M{}
+	if upd.Name != nil {
+		set["name"] = *upd.Name
+	}
+	if upd.Description != nil {
+		set["description"] = *upd.Description
+	}
+	if upd.ImageURL != nil {
+		set["imageUrl"] = *upd.ImageURL
+	}
+	if upd.Latitude != nil {
+		set["latitude"] = *upd.Latitude
+	}
+	if upd.Longitude != nil {
+		set["longitude"] = *upd.Longitude
+	}
+	if upd.Radius != nil {
+		set["radius"] = *upd.Radius
+	}
+	var unset []string
+	if upd.Geofence != nil {
+		if len(upd.Geofence.Coordinates) > 0 {
+			set["geofence"] = upd.Geofence
+		} else {
+			unset = append(unset, "geofence")
+		}
+	}
+	// values are wrapped in $literal so strings starting with "$" are not read as field paths
+	for k, v := range set {
+		set[k] = bson.M{"$literal": v}
+	}
 
-	update := bson.M{"$set": updates}
+	// pipeline update so the GeoJSON location is rebuilt from the (possibly new) coordinates
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"location": bson.M{
+			"type":        "Point",
+			"coordinates": bson.A{"$longitude", "$latitude"},
+		}}}},
+	}
+	if len(set) > 0 {
+		update = append(mongo.Pipeline{{{Key: "$set", Value: set}}}, update...)
+	}
+	if len(unset) > 0 {
+		update = append(update, bson.D{{Key: "$unset", Value: unset}})
+	}
 	var kp model.KeyPoint
 	err = r.kpCol.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&kp)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrKeyPointNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &kp, nil
 }
 
-func (r *TourRepository) DeleteKeyPoint(ctx context.Context, keypointId string) error {
+// DeleteKeyPoint removes a key point of the given tour
+func (r *TourRepository) DeleteKeyPoint(ctx context.Context, tourId primitive.ObjectID, keypointId string) error {
 	objID, err := primitive.ObjectIDFromHex(keypointId)
+	if err != nil {
+		return ErrKeyPointNotFound
+	}
+	tour, err := r.GetTourByID(ctx, tourId.Hex())
 	if err != nil {
 		return err
 	}
-	_, err = r.kpCol.DeleteOne(ctx, bson.M{"_id": objID})
-	return err
+	if tour.Status != model.TourDraft {
+		kps, err := r.editedKeyPoints(ctx, tour)
+		if err != nil {
+			return err
+		}
+		for i := range kps {
+			if kps[i].ID == objID {
+				return r.stageKeyPoints(ctx, tour, append(kps[:i], kps[i+1:]...))
+			}
+		}
+		return ErrKeyPointNotFound
+	}
+	res, err := r.kpCol.DeleteOne(ctx, bson.M{"_id": objID, "tourId": tourId})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrKeyPointNotFound
+	}
+	return nil
 }
 
+// UpdateKeyPointsOrder sets each key point's order to its index in orderedIds.
+// Every id must be a distinct key point of the tour, otherwise nothing is changed.
 func (r *TourRepository) UpdateKeyPointsOrder(ctx context.Context, tourId primitive.ObjectID, orderedIds []string) error {
-	// Update each keypoint with its new order
-	for i, idStr := range orderedIds {
+	ids := make([]primitive.ObjectID, 0, len(orderedIds))
+	seen := make(map[primitive.ObjectID]bool, len(orderedIds))
+	for _, idStr := range orderedIds {
 		objID, err := primitive.ObjectIDFromHex(idStr)
+		if err != nil || seen[objID] {
+			return ErrKeyPointNotFound
+		}
+		seen[objID] = true
+		ids = append(ids, objID)
+	}
+	tour, err := r.GetTourByID(ctx, tourId.Hex())
+	if err != nil {
+		return err
+	}
+	if tour.Status != model.TourDraft {
+		kps, err := r.editedKeyPoints(ctx, tour)
 		if err != nil {
-			continue
+			return err
+		}
+		order := make(map[primitive.ObjectID]int, len(ids))
+		for i, id := range ids {
+			order[id] = i
+		}
+		found := 0
+		for i := range kps {
+			if o, ok := order[kps[i].ID]; ok {
+				kps[i].Order = o
+				found++
+			}
 		}
+		if found != len(ids) {
+			return ErrKeyPointNotFound
+		}
+		return r.stageKeyPoints(ctx, tour, kps)
+	}
+	count, err := r.kpCol.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": ids}, "tourId": tourId})
+	if err != nil {
+		return err
+	}
+	if count != int64(len(ids)) {
+		return ErrKeyPointNotFound
+	}
+
+	// Update each keypoint with its new order
+	for i, objID := range ids {
 		filter := bson.M{"_id": objID, "tourId": tourId}
 		update := bson.M{"$set": bson.M{"order": i}}
 		_, err = r.kpCol.UpdateOne(ctx, filter, update)
@@ -223,7 +542,38 @@ func (r *TourRepository) UpdateKeyPointsOrder(ctx context.Context, tourId primit
 	return nil
 }
 
+// RecalculateRoute recomputes the tour distance along its ordered key points and, unless the guide
+// has overridden them, the estimated durations for every transport mode
+func (r *TourRepository) RecalculateRoute(ctx context.Context, tourId primitive.ObjectID) (*model.Tour, error) {
+	kps, err := r.GetKeyPointsByTour(ctx, tourId)
+	if err != nil {
+		return nil, err
+	}
+	distance := utils.RouteDistance(kps)
+	estimated := r.speeds.Estimate(distance)
+
+	// single pipeline update so a concurrent override is never clobbered by the estimate
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"distance": distance,
+			"durations": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$durationsOverride", true}},
+				"$durations",
+				bson.M{"$literal": estimated},
+			}},
+		}}},
+	}
+	var tour model.Tour
+	err = r.col.FindOneAndUpdate(ctx, bson.M{"_id": tourId}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&tour)
+	if err != nil {
+		return nil, err
+	}
+	return &tour, nil
+}
+
 // Review methods
+
+// CreateReview stores the review and adds it to the tour's rating aggregate in one transaction
 func (r *TourRepository) CreateReview(ctx context.Context, rev *model.Review) (*model.Review, error) {
 	if rev == nil {
 		return nil, mongo.ErrNilDocument
@@ -235,30 +585,64 @@ func (r *TourRepository) CreateReview(ctx context.Context, rev *model.Review) (*
 	if rev.Rating > 5 {
 		rev.Rating = 5
 	}
-	res, err := r.revCol.InsertOne(ctx, rev)
+	rev.ID = primitive.NewObjectID()
+	err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := r.revCol.InsertOne(sc, rev); err != nil {
+			return err
+		}
+		return r.applyRatingDelta(sc, rev.TourID, rev.Rating, 1)
+	})
 	if err != nil {
 		return nil, err
 	}
-	rev.ID = res.InsertedID.(primitive.ObjectID)
 	return rev, nil
 }
 
-func (r *TourRepository) GetReviewsByTour(ctx context.Context, tourId primitive.ObjectID) ([]model.Review, error) {
-	filter := bson.M{"tourId": tourId}
-	cur, err := r.revCol.Find(ctx, filter)
+// GetReviewsByTour returns one page of a tour's reviews in the requested order and the total number of reviews
+func (r *TourRepository) GetReviewsByTour(ctx context.Context, tourId primitive.ObjectID, q model.ReviewQuery) ([]model.Review, int64, error) {
+	filter := bson.M{"tourId": tourId, "hidden": bson.M{"$ne": true}}
+	var sort bson.D
+	switch q.Sort {
+	case "", model.ReviewSortNewest:
+		sort = bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}
+	case model.ReviewSortHelpful:
+		sort = bson.D{{Key: "helpfulCount", Value: -1}, {Key: "unhelpfulCount", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}
+	case model.ReviewSortRating:
+		sort = bson.D{{Key: "rating", Value: -1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}
+	default:
+		return nil, 0, ErrInvalidSort
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+
+	total, err := r.revCol.CountDocuments(ctx, filter)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	opts := options.Find().SetSort(sort).SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	cur, err := r.revCol.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
 	}
 	defer cur.Close(ctx)
-	var out []model.Review
+	out := []model.Review{}
 	for cur.Next(ctx) {
 		var rev model.Review
 		if err := cur.Decode(&rev); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		out = append(out, rev)
 	}
-	return out, nil
+	return out, total, nil
 }
 
 // HasUserReviewedTour checks if a user has already reviewed a tour
@@ -274,73 +658,29 @@ func (r *TourRepository) HasUserReviewedTour(ctx context.Context, tourId primiti
 	return count > 0, nil
 }
 
+// PublishTour makes a draft tour live and records the published tour and key points as a new version.
+// Archived tours are brought back with ActivateTour instead.
 func (r *TourRepository) PublishTour(ctx context.Context, tourId string, authorId string) (*model.Tour, error) {
-	objID, err := primitive.ObjectIDFromHex(tourId)
-	if err != nil {
-		return nil, err
-	}
-
-	now := time.Now().UTC()
-	filter := bson.M{"_id": objID, "authorId": authorId}
-	update := bson.M{
-		"$set": bson.M{
-			"status":      "published",
-			"publishedAt": now,
-			"archivedAt":  nil,
-		},
-	}
-
-	var tour model.Tour
-	err = r.col.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&tour)
+	tour, err := r.transitionTour(ctx, tourId, authorId, model.TourDraft, model.TourPublished, bson.M{
+		"publishedAt": time.Now().UTC(),
+	})
 	if err != nil {
 		return nil, err
 	}
-	return &tour, nil
+	return r.snapshotTour(ctx, tour, 0)
 }
 
 func (r *TourRepository) ArchiveTour(ctx context.Context, tourId string, authorId string) (*model.Tour, error) {
-	objID, err := primitive.ObjectIDFromHex(tourId)
-	if err != nil {
-		return nil, err
-	}
-
-	now := time.Now().UTC()
-	filter := bson.M{"_id": objID, "authorId": authorId, "status": "published"}
-	update := bson.M{
-		"$set": bson.M{
-			"status":     "archived",
-			"archivedAt": now,
-		},
-	}
-
-	var tour model.Tour
-	err = r.col.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&tour)
-	if err != nil {
-		return nil, err
-	}
-	return &tour, nil
+	return r.transitionTour(ctx, tourId, authorId, model.TourPublished, model.TourArchived, bson.M{
+		"archivedAt": time.Now().UTC(),
+	})
 }
 
+// ActivateTour brings an archived tour back to published, keeping its original publish date
 func (r *TourRepository) ActivateTour(ctx context.Context, tourId string, authorId string) (*model.Tour, error) {
-	objID, err := primitive.ObjectIDFromHex(tourId)
-	if err != nil {
-		return nil, err
-	}
-
-	filter := bson.M{"_id": objID, "authorId": authorId, "status": "archived"}
-	update := bson.M{
-		"$set": bson.M{
-			"status":     "published",
-			"archivedAt": nil,
-		},
-	}
-
-	var tour model.Tour
-	err = r.col.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&tour)
-	if err != nil {
-		return nil, err
-	}
-	return &tour, nil
+	return r.transitionTour(ctx, tourId, authorId, model.TourArchived, model.TourPublished, bson.M{
+		"archivedAt": nil,
+	})
 }
 
 // TourExecution methods
@@ -354,7 +694,7 @@ func (r *TourRepository) CreateExecution(ctx context.Context, exec *model.TourEx
 		return nil, err
 	}
 	if hasActive {
-		return nil, errors.New("tourist already has a active tour execution")
+		return nil, ErrActiveExecutionExists
 	}
 
 	exec.ID = primitive.NewObjectID()
@@ -365,10 +705,6 @@ func (r *TourRepository) CreateExecution(ctx context.Context, exec *model.TourEx
 		exec.CompletedPoints = []model.CompletedPoint{}
 	}
 
-	if exec.Locations == nil {
-		exec.Locations = []model.Location{}
-	}
-
 	res, err := r.execCol.InsertOne(ctx, exec)
 	if err != nil {
 		return nil, err
@@ -378,15 +714,19 @@ func (r *TourRepository) CreateExecution(ctx context.Context, exec *model.TourEx
 	return exec, nil
 }
 
+// GetActiveExecution returns the tourist's execution of the tour that is still in progress, active or paused
 func (r *TourRepository) GetActiveExecution(ctx context.Context, touristId string, tourId primitive.ObjectID) (*model.TourExecution, error) {
 	filter := bson.M{
 		"tourId":    tourId,
 		"touristId": touristId,
-		"status":    model.ExecutionActive,
+		"status":    bson.M{"$in": bson.A{model.ExecutionActive, model.ExecutionPaused}},
 	}
 
 	var exec model.TourExecution
 	err := r.execCol.FindOne(ctx, filter).Decode(&exec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrExecutionNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -399,47 +739,22 @@ func (r *TourRepository) UpdateExecution(ctx context.Context, exec *model.TourEx
 		return mongo.ErrNilDocument
 	}
 
-	if exec.CompletedPoints == nil {
-		exec.CompletedPoints = []model.CompletedPoint{}
-	}
-
+	// completed points are only added through CompletePoint, so a status change never drops them
 	update := bson.M{
 		"$set": bson.M{
-			"status":          exec.Status,
-			"finishedAt":      exec.FinishedAt,
-			"lastActivity":    exec.LastActivity,
-			"completedPoints": exec.CompletedPoints,
+			"status":       exec.Status,
+			"finishedAt":   exec.FinishedAt,
+			"lastActivity": exec.LastActivity,
+			"summary":      exec.Summary,
+			"pauses":       exec.Pauses,
 		},
 	}
-
-	_, err := r.execCol.UpdateOne(ctx, bson.M{"_id": exec.ID}, update)
-	return err
-}
-
-func (r *TourRepository) AddLocation(ctx context.Context, execId primitive.ObjectID, loc model.Location) error {
-	if execId.IsZero() {
-		return mongo.ErrNilDocument
-	}
-
-	// If timestamp is missing, set now
-	if loc.Timestamp.IsZero() {
-		loc.Timestamp = time.Now().UTC()
-	}
-
-	filter := bson.M{"_id": execId}
-
-	update := bson.M{
-		"$push": bson.M{
-			"locations": loc,
-		},
-		"$set": bson.M{
-			"lastActivity": time.Now().UTC(),
-		},
+	if exec.Status != model.ExecutionActive && exec.Status != model.ExecutionPaused {
+		// a finished execution is no longer shared
+		update["$unset"] = bson.M{"share": ""}
 	}
 
-	opts := options.Update().SetUpsert(false)
-
-	_, err := r.execCol.UpdateOne(ctx, filter, update, opts)
+	_, err := r.execCol.UpdateOne(ctx, bson.M{"_id": exec.ID}, update)
 	return err
 }
 
@@ -450,6 +765,9 @@ func (r *TourRepository) GetExecutionByID(ctx context.Context, id primitive.Obje
 
 	var exec model.TourExecution
 	err := r.execCol.FindOne(ctx, bson.M{"_id": id}).Decode(&exec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrExecutionNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -475,13 +793,67 @@ func (r *TourRepository) CompletePoint(ctx context.Context, execId primitive.Obj
 		},
 	}
 
-	_, err := r.execCol.UpdateOne(ctx, bson.M{"_id": execId}, update)
-	return err
+	filter := bson.M{
+		"_id":                        execId,
+		"status":                     model.ExecutionActive,
+		"completedPoints.keyPointId": bson.M{"$ne": cp.KeyPointID},
+	}
+	res, err := r.execCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		n, err := r.execCol.CountDocuments(ctx, bson.M{"_id": execId, "status": model.ExecutionActive})
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrKeyPointAlreadyReached
+		}
+		return ErrExecutionNotActive
+	}
+	return nil
 }
+
+// CompleteExecution moves an active execution to completed with its summary, but only once every
+// one of keyPointIds is among its completed points, and stops sharing it. It reports whether this call made the transition,
+// so of two requests reaching the last key points at the same time only one finishes the execution.
+func (r *TourRepository) CompleteExecution(ctx context.Context, execId primitive.ObjectID, keyPointIds []primitive.ObjectID,
+	finishedAt time.Time, summary model.ExecutionSummary) (bool, error) {
+	if execId.IsZero() || len(keyPointIds) == 0 {
+		return false, mongo.ErrNilDocument
+	}
+
+	filter := bson.M{
+		"_id":    execId,
+		"status": model.ExecutionActive,
+		"$expr": bson.M{"$setIsSubset": bson.A{
+			keyPointIds,
+			bson.M{"$ifNull": bson.A{"$completedPoints.keyPointId", bson.A{}}},
+		}},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":       model.ExecutionCompleted,
+			"finishedAt":   finishedAt,
+			"lastActivity": time.Now().UTC(),
+			"summary":      summary,
+		},
+		"$unset": bson.M{"share": ""},
+	}
+	res, err := r.execCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+// HasAnyActiveExecution reports whether the tourist has an execution in progress; a paused one counts,
+// as resuming it would otherwise leave them with two
 func (r *TourRepository) HasAnyActiveExecution(ctx context.Context, touristId string) (bool, error) {
 	filter := bson.M{
 		"touristId": touristId,
-		"status":    model.ExecutionActive,
+		"status":    bson.M{"$in": bson.A{model.ExecutionActive, model.ExecutionPaused}},
 	}
 
 	count, err := r.execCol.CountDocuments(ctx, filter)
@@ -492,6 +864,56 @@ func (r *TourRepository) HasAnyActiveExecution(ctx context.Context, touristId st
 	return count > 0, nil
 }
 
+// AbandonStaleExecutions marks every active execution idle since before cutoff and every execution
+// paused since before pausedCutoff as abandoned, finished at its last activity and no longer shared,
+// and returns how many there were
+func (r *TourRepository) AbandonStaleExecutions(ctx context.Context, cutoff, pausedCutoff time.Time) (int64, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": model.ExecutionActive, "lastActivity": bson.M{"$lt": cutoff}},
+		bson.M{"status": model.ExecutionPaused, "lastActivity": bson.M{"$lt": pausedCutoff}},
+	}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"status":     model.ExecutionAbandoned,
+			"finishedAt": "$lastActivity",
+		}}},
+		{{Key: "$unset", Value: "share"}},
+	}
+	res, err := r.execCol.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// GetReviewExecution picks the execution that best shows a tourist took the tour: the one that
+// reached the most key points, the latest on a tie. A status set by the client proves nothing, only
+// the key points the server recorded count. It returns nil if there is none.
+func (r *TourRepository) GetReviewExecution(ctx context.Context, touristId string, tourId primitive.ObjectID) (*model.TourExecution, error) {
+	// previews cover a single key point and never prove the tour was taken
+	filter := bson.M{"touristId": touristId, "tourId": tourId, "preview": bson.M{"$ne": true}}
+
+	cur, err := r.execCol.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$addFields", Value: bson.M{"reached": bson.M{"$size": bson.M{"$ifNull": bson.A{"$completedPoints", bson.A{}}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "reached", Value: -1}, {Key: "lastActivity", Value: -1}}}},
+		{{Key: "$limit", Value: 1}},
+		{{Key: "$project", Value: bson.M{"reached": 0, "locations": 0}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	if !cur.Next(ctx) {
+		return nil, cur.Err()
+	}
+	var exec model.TourExecution
+	if err := cur.Decode(&exec); err != nil {
+		return nil, err
+	}
+	return &exec, nil
+}
+
 // HasUserPurchasedTour checks if a user has purchased a specific tour
 func (r *TourRepository) HasUserPurchasedTour(ctx context.Context, userId string, tourId string) (bool, error) {
 	filter := bson.M{
@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type tourSort struct {
+	field  string
+	desc   bool
+	isTime bool
+}
+
+func resolveTourSort(sort string, transport string) (tourSort, error) {
+	if transport == "" {
+		transport = "walking"
+	}
+	switch sort {
+	case "", "newest":
+		return tourSort{field: "publishedAt", desc: true, isTime: true}, nil
+	case "oldest":
+		return tourSort{field: "publishedAt", isTime: true}, nil
+	case "price_asc":
+		return tourSort{field: "price"}, nil
+	case "price_desc":
+		return tourSort{field: "price", desc: true}, nil
+	case "distance_asc":
+		return tourSort{field: "distance"}, nil
+	case "distance_desc":
+		return tourSort{field: "distance", desc: true}, nil
+	case "duration_asc":
+		return tourSort{field: "durations." + transport}, nil
+	case "duration_desc":
+		return tourSort{field: "durations." + transport, desc: true}, nil
+	case "rating_desc":
+		return tourSort{field: "rating.average", desc: true}, nil
+	case "rating_asc":
+		return tourSort{field: "rating.average"}, nil
+	}
+	return tourSort{}, ErrInvalidSort
+}
+
+// searchCursor is the keyset position after the last returned tour: the sort value and the id tiebreaker.
+type searchCursor struct {
+	Value interface{} `json:"v"`
+	ID    string      `json:"id"`
+}
+
+func encodeCursor(s tourSort, t *model.Tour) string {
+	c := searchCursor{ID: t.ID.Hex()}
+	switch s.field {
+	case "publishedAt":
+		if t.PublishedAt != nil {
+			c.Value = t.PublishedAt.UTC().Format(time.RFC3339Nano)
+		}
+	case "price":
+		c.Value = t.Price
+	case "distance":
+		c.Value = t.Distance
+	case "durations.walking":
+		c.Value = t.Durations.Walking
+	case "durations.biking":
+		c.Value = t.Durations.Biking
+	case "durations.driving":
+		c.Value = t.Durations.Driving
+	case "rating.average":
+		c.Value = t.Rating.Average
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s tourSort, cursor string) (bson.M, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c searchCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	value := c.Value
+	if s.isTime {
+		str, ok := c.Value.(string)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		ts, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		value = ts
+	} else if _, ok := c.Value.(float64); !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	op := "$gt"
+	if s.desc {
+		op = "$lt"
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{s.field: bson.M{op: value}},
+		bson.M{s.field: value, "_id": bson.M{op: id}},
+	}}, nil
+}
+
+func buildSearchFilter(f model.TourSearchFilter) bson.M {
+	filter := bson.M{"status": model.TourPublished}
+	if len(f.Difficulties) > 0 {
+		filter["difficulty"] = bson.M{"$in": f.Difficulties}
+	}
+	if len(f.Tags) > 0 {
+		if f.MatchAllTags {
+			filter["tags"] = bson.M{"$all": f.Tags}
+		} else {
+			filter["tags"] = bson.M{"$in": f.Tags}
+		}
+	}
+	addRange(filter, "price", f.MinPrice, f.MaxPrice)
+	addRange(filter, "distance", f.MinDistance, f.MaxDistance)
+	if f.MinDuration != nil || f.MaxDuration != nil {
+		transport := f.Transport
+		if transport == "" {
+			transport = "walking"
+		}
+		var min, max *float64
+		if f.MinDuration != nil {
+			v := float64(*f.MinDuration)
+			min = &v
+		}
+		if f.MaxDuration != nil {
+			v := float64(*f.MaxDuration)
+			max = &v
+		}
+		addRange(filter, "durations."+transport, min, max)
+	}
+	return filter
+}
+
+func addRange(filter bson.M, field string, min, max *float64) {
+	if min == nil && max == nil {
+		return
+	}
+	r := bson.M{}
+	if min != nil {
+		r["$gte"] = *min
+	}
+	if max != nil {
+		r["$lte"] = *max
+	}
+	filter[field] = r
+}
+
+// SearchTours returns published tours matching the filter, ordered by the requested sort and paged by cursor.
+// Total counts every match regardless of the cursor position.
+func (r *TourRepository) SearchTours(ctx context.Context, f model.TourSearchFilter) (*model.TourSearchResult, error) {
+	s, err := resolveTourSort(f.Sort, f.Transport)
+	if err != nil {
+		return nil, err
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	filter := buildSearchFilter(f)
+	total, err := r.col.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	pageFilter := filter
+	if f.Cursor != "" {
+		after, err := decodeCursor(s, f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		pageFilter = bson.M{"$and": bson.A{filter, after}}
+	}
+
+	dir := 1
+	if s.desc {
+		dir = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: s.field, Value: dir}, {Key: "_id", Value: dir}}).
+		SetLimit(int64(limit + 1))
+	cur, err := r.col.Find(ctx, pageFilter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	tours := []model.Tour{}
+	for cur.Next(ctx) {
+		var t model.Tour
+		if err := cur.Decode(&t); err != nil {
+			return nil, err
+		}
+		tours = append(tours, t)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	res := &model.TourSearchResult{Total: total}
+	if len(tours) > limit {
+		tours = tours[:limit]
+		res.NextCursor = encodeCursor(s, &tours[len(tours)-1])
+	}
+	res.Tours = tours
+	return res, nil
+}
+
+const maxNearbyRadius = 100000.0 // meters
+
+// SearchNearby returns published tours with a key point within radius meters of lat/lng, closest first.
+// In NearbyStart mode only each tour's first key point is considered, in NearbyAny mode the closest one.
+func (r *TourRepository) SearchNearby(ctx context.Context, lat, lng, radius float64, mode string, limit int) ([]model.NearbyTour, error) {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	if radius > maxNearbyRadius {
+		radius = maxNearbyRadius
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$geoNear", Value: bson.M{
+			"near":          model.NewGeoPoint(lat, lng),
+			"distanceField": "distance",
+			"maxDistance":   radius,
+			"spherical":     true,
+			"key":           "location",
+		}}},
+	}
+	if mode == model.NearbyStart {
+		// keep a candidate only if it is the first key point of its tour (same ordering as GetKeyPointsByTour)
+		pipeline = append(pipeline,
+			bson.D{{Key: "$lookup", Value: bson.M{
+				"from": r.kpCol.Name(),
+				"let":  bson.M{"tourId": "$tourId"},
+				"pipeline": bson.A{
+					bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$tourId", "$$tourId"}}}},
+					bson.M{"$sort": bson.D{{Key: "order", Value: 1}, {Key: "createdAt", Value: 1}}},
+					bson.M{"$limit": 1},
+					bson.M{"$project": bson.M{"_id": 1}},
+				},
+				"as": "first",
+			}}},
+			bson.D{{Key: "$match", Value: bson.M{"$expr": bson.M{
+				"$eq": bson.A{"$_id", bson.M{"$arrayElemAt": bson.A{"$first._id", 0}}},
+			}}}},
+		)
+	}
+	pipeline = append(pipeline,
+		// $geoNear output is sorted by distance, so $first picks the closest key point of each tour
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":        "$tourId",
+			"distance":   bson.M{"$min": "$distance"},
+			"keyPointId": bson.M{"$first": "$_id"},
+		}}},
+		bson.D{{Key: "$lookup", Value: bson.M{
+			"from":         r.col.Name(),
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "tour",
+		}}},
+		bson.D{{Key: "$unwind", Value: "$tour"}},
+		bson.D{{Key: "$match", Value: bson.M{"tour.status": model.TourPublished}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "distance", Value: 1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: limit}},
+	)
+
+	cur, err := r.kpCol.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	out := []model.NearbyTour{}
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// snapshotTour stores the tour and its current key points as the next version and bumps currentVersion.
+// restoredFrom records the version a rollback copied, 0 for a regular publish.
+func (r *TourRepository) snapshotTour(ctx context.Context, tour *model.Tour, restoredFrom int) (*model.Tour, error) {
+	kps, err := r.GetKeyPointsByTour(ctx, tour.ID)
+	if err != nil {
+		return nil, err
+	}
+	if kps == nil {
+		kps = []model.KeyPoint{}
+	}
+
+	snap := *tour
+	snap.CurrentVersion = tour.CurrentVersion + 1
+	snap.PendingRevision = nil
+	v := model.TourVersion{
+		TourID:       tour.ID,
+		Version:      snap.CurrentVersion,
+		Tour:         snap,
+		KeyPoints:    kps,
+		PublishedAt:  time.Now().UTC(),
+		RestoredFrom: restoredFrom,
+	}
+	// the unique (tourId, version) index rejects a second snapshot racing for the same number
+	if _, err := r.verCol.InsertOne(ctx, v); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrConcurrentTourEdit
+		}
+		return nil, err
+	}
+
+	var updated model.Tour
+	err = r.col.FindOneAndUpdate(ctx,
+		bson.M{"_id": tour.ID},
+		bson.M{"$set": bson.M{"currentVersion": v.Version}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// GetTourVersions lists the versions of a tour, newest first, without their key points
+func (r *TourRepository) GetTourVersions(ctx context.Context, tourId primitive.ObjectID) ([]model.TourVersion, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "version", Value: -1}}).
+		SetProjection(bson.M{"keyPoints": 0})
+	cur, err := r.verCol.Find(ctx, bson.M{"tourId": tourId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	versions := []model.TourVersion{}
+	if err := cur.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *TourRepository) GetTourVersion(ctx context.Context, tourId primitive.ObjectID, version int) (*model.TourVersion, error) {
+	var v model.TourVersion
+	err := r.verCol.FindOne(ctx, bson.M{"tourId": tourId, "version": version}).Decode(&v)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetExecutionKeyPoints returns the key points of the tour version an execution was started against.
+// Executions started before tours were versioned fall back to the live key points.
+func (r *TourRepository) GetExecutionKeyPoints(ctx context.Context, exec *model.TourExecution) ([]model.KeyPoint, error) {
+	if exec.TourVersion == 0 {
+		return r.GetKeyPointsByTour(ctx, exec.TourID)
+	}
+	v, err := r.GetTourVersion(ctx, exec.TourID, exec.TourVersion)
+	if err != nil {
+		return nil, err
+	}
+	return v.KeyPoints, nil
+}
+
+// RollbackTour restores the tour fields and key points of an older version and records them
+// as a new version, discarding any pending revision. The tour keeps its status, so an archived
+// tour stays archived. Executions started on other versions keep theirs.
+func (r *TourRepository) RollbackTour(ctx context.Context, tourId string, authorId string, version int) (*model.Tour, error) {
+	tour, err := r.getOwnedTour(ctx, tourId, authorId)
+	if err != nil {
+		return nil, err
+	}
+	if tour.Status == model.TourDraft {
+		return nil, &TransitionError{From: tour.Status, To: model.TourPublished}
+	}
+	v, err := r.GetTourVersion(ctx, tour.ID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// roll back the tour as it was read; a concurrent transition or edit has to be reviewed again
+	filter := bson.M{"_id": tour.ID, "status": tour.Status}
+	if rev := tour.PendingRevision; rev != nil {
+		filter["pendingRevision.updatedAt"] = rev.UpdatedAt
+	} else {
+		filter["pendingRevision"] = bson.M{"$exists": false}
+	}
+	old := v.Tour
+	update := bson.M{
+		"$set": bson.M{
+			"name":                old.Name,
+			"description":         old.Description,
+			"difficulty":          old.Difficulty,
+			"tags":                old.Tags,
+			"price":               old.Price,
+			"distance":            old.Distance,
+			"durations":           old.Durations,
+			"durationsOverride":   old.DurationsOverride,
+			"sequentialKeyPoints": old.SequentialKeyPoints,
+		},
+		"$unset": bson.M{"pendingRevision": ""},
+	}
+	var restored *model.Tour
+	err = r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		var updated model.Tour
+		err := r.col.FindOneAndUpdate(sc, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrConcurrentTourEdit
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.replaceKeyPoints(sc, tour.ID, append([]model.KeyPoint(nil), v.KeyPoints...)); err != nil {
+			return err
+		}
+		restored, err = r.snapshotTour(sc, &updated, version)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
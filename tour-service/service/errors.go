@@ -0,0 +1,20 @@
+package service
+
+import "errors"
+
+// Errors returned by ExecutionService besides the repository's
+// ErrExecutionNotFound, ErrForbidden, ErrExecutionNotActive and ErrActiveExecutionExists
+var (
+	ErrInvalidCoordinates   = errors.New("invalid coordinates")
+	ErrInvalidStatus        = errors.New("invalid execution status")
+	ErrInvalidTransportMode = errors.New("transport mode must be walking, biking or driving")
+	ErrTourNotStartable     = errors.New("only published tours can be started")
+	ErrTourNotPurchased     = errors.New("buy the tour to take it, or start a preview")
+	ErrKeyPointNotInTour    = errors.New("keypoint is not part of this tour")
+	ErrNoLocation           = errors.New("no location recorded")
+	ErrTooFarFromKeyPoint   = errors.New("too far from keypoint")
+	ErrKeyPointOutOfOrder   = errors.New("this tour's key points must be reached in order")
+	ErrEmptyBatch           = errors.New("no points")
+	ErrBatchTooLarge        = errors.New("too many points in one batch")
+	ErrInvalidGroupSize     = errors.New("a group takes between 1 and 100 members")
+)
@@ -0,0 +1,682 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"tour-service/model"
+	"tour-service/repository"
+	"tour-service/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// MaxLocationBatch is the most points one offline upload may carry
+	MaxLocationBatch = 1000
+
+	// positions whose reported accuracy radius is wider than this, in meters, never complete key points
+	maxAutoCompleteAccuracy = 50.0
+	// how far a device clock may drift from the server's
+	maxClockSkew = 2 * time.Minute
+)
+
+// ExecutionRepository is the storage ExecutionService works on
+type ExecutionRepository interface {
+	CreateExecution(ctx context.Context, exec *model.TourExecution) (*model.TourExecution, error)
+	GetActiveExecution(ctx context.Context, touristId string, tourId primitive.ObjectID) (*model.TourExecution, error)
+	UpdateExecution(ctx context.Context, exec *model.TourExecution) error
+	AddLocations(ctx context.Context, execId primitive.ObjectID, locs []model.Location, latest *model.Location) error
+	GetExecutionTrack(ctx context.Context, exec *model.TourExecution) ([]model.Location, error)
+	GetRecentTrack(ctx context.Context, exec *model.TourExecution, from time.Time) ([]model.Location, error)
+	CompletePoint(ctx context.Context, execId primitive.ObjectID, cp model.CompletedPoint) error
+	CompleteExecution(ctx context.Context, execId primitive.ObjectID, keyPointIds []primitive.ObjectID, finishedAt time.Time, summary model.ExecutionSummary) (bool, error)
+	GetExecutionKeyPoints(ctx context.Context, exec *model.TourExecution) ([]model.KeyPoint, error)
+	GetTourVersion(ctx context.Context, tourId primitive.ObjectID, version int) (*model.TourVersion, error)
+	HasUserPurchasedTour(ctx context.Context, userId string, tourId string) (bool, error)
+	GetTourByID(ctx context.Context, tourId string) (*model.Tour, error)
+	GetExecutionByID(ctx context.Context, execId primitive.ObjectID) (*model.TourExecution, error)
+	FlagExecution(ctx context.Context, execId primitive.ObjectID, anomalies []model.MovementAnomaly) error
+	GetSuspiciousExecutions(ctx context.Context, tourId primitive.ObjectID) ([]model.TourExecution, error)
+	ListExecutions(ctx context.Context, touristId string, q model.ExecutionQuery) ([]model.TourExecution, int64, error)
+	PauseExecution(ctx context.Context, execId primitive.ObjectID, pause model.PauseInterval) error
+	ResumeExecution(ctx context.Context, execId primitive.ObjectID, resumedAt time.Time) error
+	CreateGroupSession(ctx context.Context, g *model.GroupSession) (*model.GroupSession, error)
+	GetGroupSession(ctx context.Context, id primitive.ObjectID) (*model.GroupSession, error)
+	JoinGroupSession(ctx context.Context, code string) (*model.GroupSession, error)
+	LeaveGroupSession(ctx context.Context, id primitive.ObjectID) error
+	CloseGroupSession(ctx context.Context, id primitive.ObjectID) error
+	GetGroupExecutions(ctx context.Context, groupId primitive.ObjectID) ([]model.TourExecution, error)
+	CompleteGroupPoint(ctx context.Context, groupId primitive.ObjectID, cp model.CompletedPoint) ([]model.TourExecution, error)
+	ShareExecution(ctx context.Context, execId primitive.ObjectID, share model.ExecutionShare) error
+	RevokeExecutionShare(ctx context.Context, execId primitive.ObjectID) error
+	GetSharedExecution(ctx context.Context, tokenHash string) (*model.TourExecution, error)
+}
+
+// ExecutionService holds the rules of taking a tour: only the tourist who started an execution
+// may drive it, only while it is active, and only with positions that are on the globe.
+type ExecutionService struct {
+	repo      ExecutionRepository
+	dwell     time.Duration
+	followers FollowerChecker
+}
+
+func NewExecutionService(repo ExecutionRepository) *ExecutionService {
+	return &ExecutionService{repo: repo}
+}
+
+// SetDwellTime makes a key point count as reached only after the tourist stayed within
+// its area for d. Zero, the default, completes it on the first fix inside.
+func (s *ExecutionService) SetDwellTime(d time.Duration) {
+	s.dwell = d
+}
+
+// Start begins an execution of a published tour, pinned to its current version. Paid tours must have
+// been bought unless the tourist is their author or asks for a preview, which covers only the first
+// key point. The transport mode bounds the speed the tourist may move at and defaults to walking.
+func (s *ExecutionService) Start(ctx context.Context, userId string, tourId string, mode model.TransportMode, preview bool) (*model.TourExecution, error) {
+	mode, err := transportMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	tour, err := s.startableTour(ctx, tourId)
+	if err != nil {
+		return nil, err
+	}
+	if !preview && tour.Price > 0 && tour.AuthorID != userId {
+		purchased, err := s.repo.HasUserPurchasedTour(ctx, userId, tour.ID.Hex())
+		if err != nil {
+			return nil, err
+		}
+		if !purchased {
+			return nil, ErrTourNotPurchased
+		}
+	}
+
+	return s.repo.CreateExecution(ctx, &model.TourExecution{
+		TourID:              tour.ID,
+		TouristID:           userId,
+		TourVersion:         tour.CurrentVersion,
+		SequentialKeyPoints: tour.SequentialKeyPoints,
+		TransportMode:       mode,
+		Preview:             preview,
+		Status:              model.ExecutionActive,
+		LastActivity:        time.Now().UTC(),
+	})
+}
+
+func transportMode(mode model.TransportMode) (model.TransportMode, error) {
+	if mode == "" {
+		return model.TransportWalking, nil
+	}
+	if !mode.Valid() {
+		return "", ErrInvalidTransportMode
+	}
+	return mode, nil
+}
+
+// startableTour loads a tour executions may be started on
+func (s *ExecutionService) startableTour(ctx context.Context, tourId string) (*model.Tour, error) {
+	tour, err := s.repo.GetTourByID(ctx, tourId)
+	if errors.Is(err, primitive.ErrInvalidHex) {
+		return nil, repository.ErrTourNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tour.Status != model.TourPublished {
+		return nil, ErrTourNotStartable
+	}
+	return tour, nil
+}
+
+// GetActive returns the user's running execution of a tour
+func (s *ExecutionService) GetActive(ctx context.Context, userId string, tourId primitive.ObjectID) (*model.TourExecution, error) {
+	return s.repo.GetActiveExecution(ctx, userId, tourId)
+}
+
+// GetTracked loads an execution that belongs to the user and is still active
+func (s *ExecutionService) GetTracked(ctx context.Context, execId primitive.ObjectID, userId string) (*model.TourExecution, error) {
+	exec, err := s.getOwned(ctx, execId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if exec.Status != model.ExecutionActive {
+		return nil, repository.ErrExecutionNotActive
+	}
+	return exec, nil
+}
+
+func (s *ExecutionService) getOwned(ctx context.Context, execId primitive.ObjectID, userId string) (*model.TourExecution, error) {
+	exec, err := s.repo.GetExecutionByID(ctx, execId)
+	if err != nil {
+		return nil, err
+	}
+	if exec.TouristID != userId {
+		return nil, repository.ErrForbidden
+	}
+	return exec, nil
+}
+
+// Pause stops tracking an active execution while the tourist takes a break. The break is left out
+// of the execution's duration and a paused execution is abandoned only after the longer pause timeout.
+func (s *ExecutionService) Pause(ctx context.Context, execId primitive.ObjectID, userId string) error {
+	if _, err := s.getOwned(ctx, execId, userId); err != nil {
+		return err
+	}
+	return s.repo.PauseExecution(ctx, execId, model.PauseInterval{Start: time.Now().UTC()})
+}
+
+// Resume continues tracking a paused execution
+func (s *ExecutionService) Resume(ctx context.Context, execId primitive.ObjectID, userId string) error {
+	if _, err := s.getOwned(ctx, execId, userId); err != nil {
+		return err
+	}
+	return s.repo.ResumeExecution(ctx, execId, time.Now().UTC())
+}
+
+// List returns one page of the user's executions and how many match the query in total
+func (s *ExecutionService) List(ctx context.Context, userId string, q model.ExecutionQuery) ([]model.TourExecution, int64, error) {
+	switch q.Status {
+	case "", model.ExecutionActive, model.ExecutionPaused, model.ExecutionCompleted, model.ExecutionAbandoned:
+	default:
+		return nil, 0, ErrInvalidStatus
+	}
+	return s.repo.ListExecutions(ctx, userId, q)
+}
+
+// Get loads one of the user's executions in any status with its whole track. Executions that did not
+// complete have no stored summary, so one is computed up to when they were abandoned or, while active, up to now.
+func (s *ExecutionService) Get(ctx context.Context, execId primitive.ObjectID, userId string) (*model.TourExecution, error) {
+	exec, err := s.getOwned(ctx, execId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if exec.Locations, err = s.repo.GetExecutionTrack(ctx, exec); err != nil {
+		return nil, err
+	}
+	if exec.Summary == nil {
+		until := time.Now().UTC()
+		if exec.FinishedAt != nil {
+			until = *exec.FinishedAt
+		}
+		summary := summarize(timeline(exec.Locations, nil), exec.StartedAt, until, exec.Pauses)
+		exec.Summary = &summary
+	}
+	return exec, nil
+}
+
+// Replay returns one of the user's executions with the name of its tour and the key points of the
+// version it followed, which is what an export of the walk needs
+func (s *ExecutionService) Replay(ctx context.Context, execId primitive.ObjectID, userId string) (*model.TourExecution, string, []model.KeyPoint, error) {
+	exec, err := s.Get(ctx, execId, userId)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	tour, err := s.repo.GetTourByID(ctx, exec.TourID.Hex())
+	if err != nil {
+		return nil, "", nil, err
+	}
+	kps, err := s.keyPoints(ctx, exec)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return exec, tour.Name, kps, nil
+}
+
+// ListSuspicious returns the executions of a tour flagged for implausible movement; only its author may see them
+func (s *ExecutionService) ListSuspicious(ctx context.Context, tourId string, userId string) ([]model.TourExecution, error) {
+	tour, err := s.repo.GetTourByID(ctx, tourId)
+	if errors.Is(err, primitive.ErrInvalidHex) {
+		return nil, repository.ErrTourNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tour.AuthorID != userId {
+		return nil, repository.ErrForbidden
+	}
+	return s.repo.GetSuspiciousExecutions(ctx, tour.ID)
+}
+
+// UpdateStatus moves an active or paused execution to the given status, ending a pause in progress.
+// Reached key points are only ever recorded by the server, so the ones stored are kept as they are.
+func (s *ExecutionService) UpdateStatus(ctx context.Context, execId primitive.ObjectID, userId string, status model.ExecutionStatus) error {
+	switch status {
+	case model.ExecutionActive, model.ExecutionCompleted, model.ExecutionAbandoned:
+	default:
+		return ErrInvalidStatus
+	}
+
+	exec, err := s.getOwned(ctx, execId, userId)
+	if err != nil {
+		return err
+	}
+	if exec.Status != model.ExecutionActive && exec.Status != model.ExecutionPaused {
+		return repository.ErrExecutionNotActive
+	}
+
+	now := time.Now().UTC()
+	for i := range exec.Pauses {
+		if exec.Pauses[i].End == nil {
+			exec.Pauses[i].End = &now
+		}
+	}
+
+	update := &model.TourExecution{
+		ID:           exec.ID,
+		Status:       status,
+		LastActivity: now,
+		Pauses:       exec.Pauses,
+	}
+	if status != model.ExecutionActive {
+		update.FinishedAt = &now
+	}
+	if status == model.ExecutionCompleted {
+		locs, err := s.repo.GetExecutionTrack(ctx, exec)
+		if err != nil {
+			return err
+		}
+		summary := summarize(timeline(locs, nil), exec.StartedAt, now, exec.Pauses)
+		update.Summary = &summary
+	}
+	return s.repo.UpdateExecution(ctx, update)
+}
+
+// RecordLocation tracks the tourist's current position
+func (s *ExecutionService) RecordLocation(ctx context.Context, execId primitive.ObjectID, userId string, lat, lng float64) ([]model.ExecutionEvent, error) {
+	if !utils.ValidCoordinates(lat, lng) {
+		return nil, ErrInvalidCoordinates
+	}
+	exec, err := s.GetTracked(ctx, execId, userId)
+	if err != nil {
+		return nil, err
+	}
+	loc := model.Location{Latitude: lat, Longitude: lng, Timestamp: time.Now().UTC()}
+	recorded, err := s.repo.GetRecentTrack(ctx, exec, loc.Timestamp.Add(-s.dwell))
+	if err != nil {
+		return nil, err
+	}
+	return s.track(ctx, exec, recorded, []model.Location{loc})
+}
+
+// LocationPoint is a position buffered on the device while it was offline
+type LocationPoint struct {
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Timestamp time.Time `json:"timestamp"`
+	Accuracy  *float64  `json:"accuracy,omitempty"`
+}
+
+type RejectedPoint struct {
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+}
+
+type LocationBatchResult struct {
+	Accepted   int                    `json:"accepted"`
+	Duplicates int                    `json:"duplicates"`
+	Rejected   []RejectedPoint        `json:"rejected"`
+	Events     []model.ExecutionEvent `json:"events"`
+}
+
+// checkLocationPoint returns why a buffered point cannot be recorded, or "" if it can
+func checkLocationPoint(p LocationPoint, startedAt, now time.Time) string {
+	switch {
+	case !utils.ValidCoordinates(p.Latitude, p.Longitude):
+		return ErrInvalidCoordinates.Error()
+	case p.Timestamp.IsZero():
+		return "missing timestamp"
+	case p.Timestamp.Before(startedAt.Add(-maxClockSkew)):
+		return "recorded before the execution started"
+	case p.Timestamp.After(now.Add(maxClockSkew)):
+		return "timestamp is in the future"
+	case p.Accuracy != nil && (*p.Accuracy < 0 || math.IsNaN(*p.Accuracy)):
+		return "invalid accuracy"
+	}
+	return ""
+}
+
+// RecordLocations tracks positions uploaded after the tourist was offline. Invalid points are
+// skipped and reported by index, points whose timestamp is already recorded are dropped as
+// duplicates, and the rest are tracked in timestamp order.
+func (s *ExecutionService) RecordLocations(ctx context.Context, execId primitive.ObjectID, userId string, points []LocationPoint) (*LocationBatchResult, error) {
+	if len(points) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if len(points) > MaxLocationBatch {
+		return nil, ErrBatchTooLarge
+	}
+	exec, err := s.GetTracked(ctx, execId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// MongoDB keeps millisecond precision, so that is what makes two timestamps equal
+	seen := make(map[int64]bool, len(points))
+	res := &LocationBatchResult{Rejected: []RejectedPoint{}, Events: []model.ExecutionEvent{}}
+	now := time.Now().UTC()
+	locs := make([]model.Location, 0, len(points))
+	for i, p := range points {
+		if reason := checkLocationPoint(p, exec.StartedAt, now); reason != "" {
+			res.Rejected = append(res.Rejected, RejectedPoint{Index: i, Reason: reason})
+			continue
+		}
+		ts := p.Timestamp.UTC().Truncate(time.Millisecond)
+		if seen[ts.UnixMilli()] {
+			res.Duplicates++
+			continue
+		}
+		seen[ts.UnixMilli()] = true
+		locs = append(locs, model.Location{
+			Latitude:  p.Latitude,
+			Longitude: p.Longitude,
+			Timestamp: ts,
+			Accuracy:  p.Accuracy,
+		})
+	}
+	if len(locs) == 0 {
+		return res, nil
+	}
+	sort.SliceStable(locs, func(i, j int) bool { return locs[i].Timestamp.Before(locs[j].Timestamp) })
+
+	// only the part of the track from the oldest point on can hold the same timestamps
+	recorded, err := s.repo.GetRecentTrack(ctx, exec, locs[0].Timestamp.Add(-s.dwell))
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[int64]bool, len(recorded))
+	for _, loc := range recorded {
+		stored[loc.Timestamp.UnixMilli()] = true
+	}
+	fresh := locs[:0]
+	for _, loc := range locs {
+		if stored[loc.Timestamp.UnixMilli()] {
+			res.Duplicates++
+			continue
+		}
+		fresh = append(fresh, loc)
+	}
+	res.Accepted = len(fresh)
+
+	events, err := s.track(ctx, exec, recorded, fresh)
+	if events != nil {
+		res.Events = events
+	}
+	return res, err
+}
+
+// CompletePoint marks a key point reached by hand, which requires the last recorded position to be near it.
+// Completing the last outstanding key point also completes the execution, reported by a second event.
+func (s *ExecutionService) CompletePoint(ctx context.Context, execId primitive.ObjectID, userId string, kpId primitive.ObjectID) ([]model.ExecutionEvent, error) {
+	exec, err := s.GetTracked(ctx, execId, userId)
+	if err != nil {
+		return nil, err
+	}
+	kps, err := s.keyPoints(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	reached := reachedKeyPoints(exec)
+	var keypoint *model.KeyPoint
+	for i := range kps {
+		if kps[i].ID == kpId {
+			keypoint = &kps[i]
+			break
+		}
+	}
+	if keypoint == nil {
+		return nil, ErrKeyPointNotInTour
+	}
+	if exec.SequentialKeyPoints && !reached[kpId] && kps[nextKeyPoint(kps, reached)].ID != kpId {
+		return nil, ErrKeyPointOutOfOrder
+	}
+
+	lastLoc := exec.LastLocation
+	// executions recorded before the last known position was kept only have their embedded track
+	for i := len(exec.Locations) - 1; lastLoc == nil && i >= 0; i-- {
+		if !exec.Locations[i].Flagged {
+			lastLoc = &exec.Locations[i]
+		}
+	}
+	if lastLoc == nil {
+		return nil, ErrNoLocation
+	}
+	if !utils.InKeyPointArea(lastLoc.Latitude, lastLoc.Longitude, *keypoint) {
+		return nil, ErrTooFarFromKeyPoint
+	}
+
+	cp := model.CompletedPoint{KeyPointID: kpId, ReachedAt: time.Now().UTC()}
+	events := []model.ExecutionEvent{{
+		Type:        model.EventKeyPointReached,
+		ExecutionID: exec.ID,
+		KeyPoint:    keypoint,
+		Timestamp:   cp.ReachedAt,
+	}}
+	// a key point is recorded once, repeating the request only repeats the event
+	if reached[kpId] {
+		return events, nil
+	}
+	if err := s.repo.CompletePoint(ctx, exec.ID, cp); err != nil {
+		if errors.Is(err, repository.ErrKeyPointAlreadyReached) {
+			return events, nil
+		}
+		return nil, err
+	}
+	exec.CompletedPoints = append(exec.CompletedPoints, cp)
+	reached[kpId] = true
+
+	done, err := s.finishIfComplete(ctx, exec, kps, reached)
+	if done != nil {
+		events = append(events, *done)
+	}
+	return events, err
+}
+
+// finishIfComplete completes the execution once every key point is reached, finished when the last
+// one was. It returns the completed event, or nil when key points are outstanding or a concurrent
+// request already finished the execution. Previews stay active until abandoned, reaching their
+// single key point is not taking the tour.
+func (s *ExecutionService) finishIfComplete(ctx context.Context, exec *model.TourExecution, kps []model.KeyPoint,
+	reached map[primitive.ObjectID]bool) (*model.ExecutionEvent, error) {
+	if exec.Preview || len(kps) == 0 || nextKeyPoint(kps, reached) < len(kps) {
+		return nil, nil
+	}
+
+	ids := make([]primitive.ObjectID, len(kps))
+	for i := range kps {
+		ids[i] = kps[i].ID
+	}
+	var finishedAt time.Time
+	for _, cp := range exec.CompletedPoints {
+		if cp.ReachedAt.After(finishedAt) {
+			finishedAt = cp.ReachedAt
+		}
+	}
+	locs, err := s.repo.GetExecutionTrack(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+	summary := summarize(timeline(locs, nil), exec.StartedAt, finishedAt, exec.Pauses)
+
+	ok, err := s.repo.CompleteExecution(ctx, exec.ID, ids, finishedAt, summary)
+	if err != nil || !ok {
+		return nil, err
+	}
+	exec.Status = model.ExecutionCompleted
+	exec.FinishedAt = &finishedAt
+	exec.Summary = &summary
+	return &model.ExecutionEvent{
+		Type:        model.EventCompleted,
+		ExecutionID: exec.ID,
+		Progress:    100,
+		Summary:     &summary,
+		Timestamp:   finishedAt,
+	}, nil
+}
+
+// keyPoints returns the key points of the tour version the execution follows, only the first one for a preview
+func (s *ExecutionService) keyPoints(ctx context.Context, exec *model.TourExecution) ([]model.KeyPoint, error) {
+	kps, err := s.repo.GetExecutionKeyPoints(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+	if exec.Preview && len(kps) > 1 {
+		kps = kps[:1]
+	}
+	return kps, nil
+}
+
+func reachedKeyPoints(exec *model.TourExecution) map[primitive.ObjectID]bool {
+	reached := make(map[primitive.ObjectID]bool, len(exec.CompletedPoints))
+	for _, cp := range exec.CompletedPoints {
+		reached[cp.KeyPointID] = true
+	}
+	return reached
+}
+
+// nextKeyPoint returns the index of the first key point in tour order not reached yet, len(kps) if all are
+func nextKeyPoint(kps []model.KeyPoint, reached map[primitive.ObjectID]bool) int {
+	for i := range kps {
+		if !reached[kps[i].ID] {
+			return i
+		}
+	}
+	return len(kps)
+}
+
+// track records positions already sorted by timestamp against the recent part of the track as
+// GetRecentTrack returns it, storing the fixes downsample keeps. Fixes that moved implausibly fast since the
+// previous one are flagged, recorded as anomalies and mark the execution suspicious. The remaining
+// fixes complete key points in timestamp order, each stamped with the time of the fix that reached it
+// after the tourist stayed inside its area for the configured dwell time. Tours with sequential key
+// points only accept the next key point in order. Fixes less accurate than
+// maxAutoCompleteAccuracy complete nothing. It returns one event per key point reached, then a
+// progress update for the last position. Once the tourist has reached the first key point, straying
+// more than utils.OffRouteThreshold from the route adds an off-route warning. Reaching the last
+// outstanding key point completes the execution and ends the events with its summary.
+func (s *ExecutionService) track(ctx context.Context, exec *model.TourExecution, recorded []model.Location, locs []model.Location) ([]model.ExecutionEvent, error) {
+	if len(locs) == 0 {
+		return nil, nil
+	}
+	kps, err := s.keyPoints(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	fixes := timeline(recorded, locs)
+	anomalies := screenMovement(fixes, exec.TransportMode)
+	var latest *model.Location
+	for i := len(locs) - 1; latest == nil && i >= 0; i-- {
+		if !locs[i].Flagged {
+			latest = &locs[i]
+		}
+	}
+	if err := s.repo.AddLocations(ctx, exec.ID, downsample(fixes), latest); err != nil {
+		return nil, err
+	}
+	if len(anomalies) > 0 {
+		if err := s.repo.FlagExecution(ctx, exec.ID, anomalies); err != nil {
+			return nil, err
+		}
+		exec.Suspicious = true
+		exec.Anomalies = append(exec.Anomalies, anomalies...)
+	}
+
+	reached := reachedKeyPoints(exec)
+	var events []model.ExecutionEvent
+	stays := newDwellTracker(s.dwell)
+	for _, f := range fixes {
+		if f.loc.Flagged || fixAccuracy(f.loc) > maxAutoCompleteAccuracy {
+			continue
+		}
+		for i := range kps {
+			kp := kps[i]
+			// every stay is observed so a dwell that began before the previous key point was reached still counts
+			inside := stays.observe(i, kp, f.loc)
+			if reached[kp.ID] || !inside || !f.fresh {
+				continue
+			}
+			if exec.SequentialKeyPoints && i != nextKeyPoint(kps, reached) {
+				continue
+			}
+			cp := model.CompletedPoint{KeyPointID: kp.ID, ReachedAt: f.loc.Timestamp}
+			if err := s.repo.CompletePoint(ctx, exec.ID, cp); err != nil {
+				if errors.Is(err, repository.ErrKeyPointAlreadyReached) {
+					// a concurrent request recorded it first
+					reached[kp.ID] = true
+					continue
+				}
+				return events, err
+			}
+			exec.CompletedPoints = append(exec.CompletedPoints, cp)
+			reached[kp.ID] = true
+			events = append(events, model.ExecutionEvent{
+				Type:        model.EventKeyPointReached,
+				ExecutionID: exec.ID,
+				KeyPoint:    &kp,
+				Timestamp:   f.loc.Timestamp,
+			})
+		}
+	}
+
+	newlyReached := len(events) > 0
+
+	// report progress from the latest believable position
+	last := locs[len(locs)-1]
+	for i := len(locs) - 1; i >= 0; i-- {
+		if !locs[i].Flagged {
+			last = locs[i]
+			break
+		}
+	}
+	progress := model.ExecutionEvent{
+		Type:        model.EventProgress,
+		ExecutionID: exec.ID,
+		Timestamp:   last.Timestamp,
+	}
+	n := 0
+	for i := range kps {
+		if reached[kps[i].ID] {
+			n++
+		} else if progress.NextKeyPoint == nil {
+			next := kps[i]
+			d := math.Round(utils.HaversineDistance(last.Latitude, last.Longitude, next.Latitude, next.Longitude))
+			progress.NextKeyPoint = &next
+			progress.DistanceToNext = &d
+		}
+	}
+	if len(kps) > 0 {
+		progress.Progress = math.Round(float64(n)/float64(len(kps))*1000) / 10
+	}
+	events = append(events, progress)
+
+	if n > 0 && n < len(kps) {
+		if d := math.Round(utils.DistanceToRoute(last.Latitude, last.Longitude, kps)); d > utils.OffRouteThreshold {
+			events = append(events, model.ExecutionEvent{
+				Type:              model.EventOffRoute,
+				ExecutionID:       exec.ID,
+				Progress:          progress.Progress,
+				NextKeyPoint:      progress.NextKeyPoint,
+				DistanceToNext:    progress.DistanceToNext,
+				DistanceFromRoute: &d,
+				Timestamp:         last.Timestamp,
+			})
+		}
+	}
+
+	if newlyReached {
+		done, err := s.finishIfComplete(ctx, exec, kps, reached)
+		if done != nil {
+			events = append(events, *done)
+		}
+		if err != nil {
+			return events, err
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"tour-service/model"
+	"tour-service/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const tourist = "tourist-1"
+
+var errStorage = errors.New("storage unavailable")
+
+// fakeExecRepo keeps executions in memory. Methods the tests do not reach are left to the embedded
+// interface and panic if called.
+type fakeExecRepo struct {
+	ExecutionRepository
+
+	execs     map[primitive.ObjectID]*model.TourExecution
+	tracks    map[primitive.ObjectID][]model.Location
+	keyPoints []model.KeyPoint
+
+	getErr error // returned by GetExecutionByID
+	addErr error // returned by AddLocations
+	pushes int   // completed points written
+}
+
+func newFakeExecRepo(kps ...model.KeyPoint) *fakeExecRepo {
+	return &fakeExecRepo{
+		execs:     map[primitive.ObjectID]*model.TourExecution{},
+		tracks:    map[primitive.ObjectID][]model.Location{},
+		keyPoints: kps,
+	}
+}
+
+func (f *fakeExecRepo) add(exec model.TourExecution) primitive.ObjectID {
+	exec.ID = primitive.NewObjectID()
+	f.execs[exec.ID] = &exec
+	return exec.ID
+}
+
+func (f *fakeExecRepo) GetExecutionByID(ctx context.Context, id primitive.ObjectID) (*model.TourExecution, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	exec, ok := f.execs[id]
+	if !ok {
+		return nil, repository.ErrExecutionNotFound
+	}
+	cp := *exec
+	cp.CompletedPoints = append([]model.CompletedPoint(nil), exec.CompletedPoints...)
+	return &cp, nil
+}
+
+func (f *fakeExecRepo) GetExecutionKeyPoints(ctx context.Context, exec *model.TourExecution) ([]model.KeyPoint, error) {
+	return f.keyPoints, nil
+}
+
+func (f *fakeExecRepo) GetRecentTrack(ctx context.Context, exec *model.TourExecution, from time.Time) ([]model.Location, error) {
+	return f.tracks[exec.ID], nil
+}
+
+func (f *fakeExecRepo) GetExecutionTrack(ctx context.Context, exec *model.TourExecution) ([]model.Location, error) {
+	return f.tracks[exec.ID], nil
+}
+
+func (f *fakeExecRepo) AddLocations(ctx context.Context, id primitive.ObjectID, locs []model.Location, latest *model.Location) error {
+	if f.addErr != nil {
+		return f.addErr
+	}
+	f.tracks[id] = append(f.tracks[id], locs...)
+	if latest != nil {
+		f.execs[id].LastLocation = latest
+	}
+	return nil
+}
+
+func (f *fakeExecRepo) FlagExecution(ctx context.Context, id primitive.ObjectID, anomalies []model.MovementAnomaly) error {
+	f.execs[id].Suspicious = true
+	return nil
+}
+
+func (f *fakeExecRepo) CompletePoint(ctx context.Context, id primitive.ObjectID, cp model.CompletedPoint) error {
+	exec := f.execs[id]
+	if exec.Status != model.ExecutionActive {
+		return repository.ErrExecutionNotActive
+	}
+	for _, done := range exec.CompletedPoints {
+		if done.KeyPointID == cp.KeyPointID {
+			return repository.ErrKeyPointAlreadyReached
+		}
+	}
+	exec.CompletedPoints = append(exec.CompletedPoints, cp)
+	f.pushes++
+	return nil
+}
+
+func (f *fakeExecRepo) CompleteExecution(ctx context.Context, id primitive.ObjectID, keyPointIds []primitive.ObjectID,
+	finishedAt time.Time, summary model.ExecutionSummary) (bool, error) {
+	exec := f.execs[id]
+	if exec.Status != model.ExecutionActive {
+		return false, nil
+	}
+	exec.Status = model.ExecutionCompleted
+	exec.FinishedAt = &finishedAt
+	exec.Summary = &summary
+	return true, nil
+}
+
+func (f *fakeExecRepo) UpdateExecution(ctx context.Context, upd *model.TourExecution) error {
+	exec := f.execs[upd.ID]
+	exec.Status = upd.Status
+	exec.FinishedAt = upd.FinishedAt
+	exec.LastActivity = upd.LastActivity
+	exec.Summary = upd.Summary
+	exec.Pauses = upd.Pauses
+	return nil
+}
+
+func keyPointAt(lat, lng float64) model.KeyPoint {
+	return model.KeyPoint{ID: primitive.NewObjectID(), Latitude: lat, Longitude: lng}
+}
+
+func activeExecution() model.TourExecution {
+	return model.TourExecution{
+		TourID:    primitive.NewObjectID(),
+		TouristID: tourist,
+		Status:    model.ExecutionActive,
+		StartedAt: time.Now().UTC().Add(-time.Hour),
+	}
+}
+
+func TestRecordLocationRejectsOtherTourists(t *testing.T) {
+	repo := newFakeExecRepo(keyPointAt(45.25, 19.84))
+	id := repo.add(activeExecution())
+
+	_, err := NewExecutionService(repo).RecordLocation(context.Background(), id, "someone-else", 45.25, 19.84)
+	if !errors.Is(err, repository.ErrForbidden) {
+		t.Fatalf("got %v, want ErrForbidden", err)
+	}
+	if len(repo.tracks[id]) != 0 {
+		t.Fatal("a position of another tourist was recorded")
+	}
+}
+
+func TestRecordLocationRequiresActiveExecution(t *testing.T) {
+	for _, status := range []model.ExecutionStatus{model.ExecutionPaused, model.ExecutionCompleted, model.ExecutionAbandoned} {
+		t.Run(string(status), func(t *testing.T) {
+			repo := newFakeExecRepo(keyPointAt(45.25, 19.84))
+			exec := activeExecution()
+			exec.Status = status
+			id := repo.add(exec)
+
+			_, err := NewExecutionService(repo).RecordLocation(context.Background(), id, tourist, 45.25, 19.84)
+			if !errors.Is(err, repository.ErrExecutionNotActive) {
+				t.Fatalf("got %v, want ErrExecutionNotActive", err)
+			}
+		})
+	}
+}
+
+func TestRecordLocationRejectsInvalidCoordinates(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lng float64
+	}{
+		{"latitude above range", 90.5, 19.84},
+		{"latitude below range", -91, 19.84},
+		{"longitude above range", 45.25, 180.1},
+		{"longitude below range", 45.25, -200},
+		{"latitude NaN", math.NaN(), 19.84},
+		{"longitude NaN", 45.25, math.NaN()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeExecRepo(keyPointAt(45.25, 19.84))
+			id := repo.add(activeExecution())
+
+			_, err := NewExecutionService(repo).RecordLocation(context.Background(), id, tourist, tt.lat, tt.lng)
+			if !errors.Is(err, ErrInvalidCoordinates) {
+				t.Fatalf("got %v, want ErrInvalidCoordinates", err)
+			}
+			if len(repo.tracks[id]) != 0 {
+				t.Fatal("an invalid position was recorded")
+			}
+		})
+	}
+}
+
+func TestRecordLocationPropagatesRepositoryErrors(t *testing.T) {
+	t.Run("loading the execution", func(t *testing.T) {
+		repo := newFakeExecRepo(keyPointAt(45.25, 19.84))
+		id := repo.add(activeExecution())
+		repo.getErr = errStorage
+
+		_, err := NewExecutionService(repo).RecordLocation(context.Background(), id, tourist, 45.25, 19.84)
+		if !errors.Is(err, errStorage) {
+			t.Fatalf("got %v, want the repository error", err)
+		}
+	})
+	t.Run("storing the position", func(t *testing.T) {
+		repo := newFakeExecRepo(keyPointAt(45.25, 19.84))
+		id := repo.add(activeExecution())
+		repo.addErr = errStorage
+
+		_, err := NewExecutionService(repo).RecordLocation(context.Background(), id, tourist, 45.25, 19.84)
+		if !errors.Is(err, errStorage) {
+			t.Fatalf("got %v, want the repository error", err)
+		}
+		if repo.pushes != 0 {
+			t.Fatal("a key point was completed although the position was not stored")
+		}
+	})
+	t.Run("unknown execution", func(t *testing.T) {
+		repo := newFakeExecRepo()
+		_, err := NewExecutionService(repo).RecordLocation(context.Background(), primitive.NewObjectID(), tourist, 45.25, 19.84)
+		if !errors.Is(err, repository.ErrExecutionNotFound) {
+			t.Fatalf("got %v, want ErrExecutionNotFound", err)
+		}
+	})
+}
+
+func TestRecordLocationCompletesExecutionAtLastKeyPoint(t *testing.T) {
+	first, last := keyPointAt(45.2500, 19.8400), keyPointAt(45.2550, 19.8450)
+	repo := newFakeExecRepo(first, last)
+	exec := activeExecution()
+	exec.CompletedPoints = []model.CompletedPoint{{KeyPointID: first.ID, ReachedAt: time.Now().UTC().Add(-10 * time.Minute)}}
+	id := repo.add(exec)
+
+	events, err := NewExecutionService(repo).RecordLocation(context.Background(), id, tourist, last.Latitude, last.Longitude)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reached, completed bool
+	for _, e := range events {
+		switch e.Type {
+		case model.EventKeyPointReached:
+			reached = e.KeyPoint != nil && e.KeyPoint.ID == last.ID
+		case model.EventCompleted:
+			completed = e.Progress == 100 && e.Summary != nil
+		}
+	}
+	if !reached || !completed {
+		t.Fatalf("events %+v, want the last key point reached and the execution completed", events)
+	}
+	stored := repo.execs[id]
+	if stored.Status != model.ExecutionCompleted || stored.FinishedAt == nil {
+		t.Fatalf("stored execution is %s, want completed with a finish time", stored.Status)
+	}
+	if len(stored.CompletedPoints) != 2 {
+		t.Fatalf("stored %d completed points, want 2", len(stored.CompletedPoints))
+	}
+}
+
+func TestRecordLocationAwayFromKeyPointsKeepsExecutionActive(t *testing.T) {
+	repo := newFakeExecRepo(keyPointAt(45.25, 19.84))
+	id := repo.add(activeExecution())
+
+	if _, err := NewExecutionService(repo).RecordLocation(context.Background(), id, tourist, 45.30, 19.90); err != nil {
+		t.Fatal(err)
+	}
+	if stored := repo.execs[id]; stored.Status != model.ExecutionActive || len(stored.CompletedPoints) != 0 {
+		t.Fatalf("execution is %s with %d completed points, want active with none", stored.Status, len(stored.CompletedPoints))
+	}
+}
+
+func TestCompletePointRecordsKeyPointOnce(t *testing.T) {
+	first, last := keyPointAt(45.2500, 19.8400), keyPointAt(45.2550, 19.8450)
+	repo := newFakeExecRepo(first, last)
+	exec := activeExecution()
+	exec.LastLocation = &model.Location{Latitude: first.Latitude, Longitude: first.Longitude, Timestamp: time.Now().UTC()}
+	id := repo.add(exec)
+	svc := NewExecutionService(repo)
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.CompletePoint(context.Background(), id, tourist, first.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if repo.pushes != 1 || len(repo.execs[id].CompletedPoints) != 1 {
+		t.Fatalf("key point written %d times, want once", repo.pushes)
+	}
+}
+
+func TestUpdateStatusKeepsRecordedKeyPoints(t *testing.T) {
+	first, last := keyPointAt(45.2500, 19.8400), keyPointAt(45.2550, 19.8450)
+	repo := newFakeExecRepo(first, last)
+	reachedAt := time.Now().UTC().Add(-time.Minute)
+	exec := activeExecution()
+	exec.CompletedPoints = []model.CompletedPoint{{KeyPointID: first.ID, ReachedAt: reachedAt}}
+	id := repo.add(exec)
+
+	if err := NewExecutionService(repo).UpdateStatus(context.Background(), id, tourist, model.ExecutionAbandoned); err != nil {
+		t.Fatal(err)
+	}
+	stored := repo.execs[id]
+	if stored.Status != model.ExecutionAbandoned {
+		t.Fatalf("status %s, want abandoned", stored.Status)
+	}
+	if len(stored.CompletedPoints) != 1 || !stored.CompletedPoints[0].ReachedAt.Equal(reachedAt) {
+		t.Fatalf("completed points %+v, want the recorded one untouched", stored.CompletedPoints)
+	}
+}
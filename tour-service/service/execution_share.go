@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math"
+	"time"
+
+	"tour-service/model"
+	"tour-service/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DefaultShareTTL = 4 * time.Hour
+	MaxShareTTL     = 24 * time.Hour
+)
+
+// FollowerChecker tells whether one user follows another
+type FollowerChecker interface {
+	IsFollower(ctx context.Context, userId string, followerId string) (bool, error)
+}
+
+// SetFollowerChecker lets the tourist's followers watch executions shared with them.
+// Without one only the share token opens a shared execution.
+func (s *ExecutionService) SetFollowerChecker(fc FollowerChecker) {
+	s.followers = fc
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Share starts sharing an execution in progress for ttl, DefaultShareTTL when zero and at most
+// MaxShareTTL, and returns the new token. Sharing again replaces the earlier token; sharing
+// stops by itself when the execution finishes.
+func (s *ExecutionService) Share(ctx context.Context, execId primitive.ObjectID, userId string, ttl time.Duration, followers bool) (*model.ShareGrant, error) {
+	if ttl <= 0 {
+		ttl = DefaultShareTTL
+	}
+	if ttl > MaxShareTTL {
+		ttl = MaxShareTTL
+	}
+	if _, err := s.getOwned(ctx, execId, userId); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	now := time.Now().UTC()
+	share := model.ExecutionShare{
+		TokenHash: hashShareToken(token),
+		Followers: followers,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.repo.ShareExecution(ctx, execId, share); err != nil {
+		return nil, err
+	}
+	return &model.ShareGrant{Token: token, Followers: followers, ExpiresAt: share.ExpiresAt}, nil
+}
+
+// Unshare revokes the execution's share token and its followers' access
+func (s *ExecutionService) Unshare(ctx context.Context, execId primitive.ObjectID, userId string) error {
+	if _, err := s.getOwned(ctx, execId, userId); err != nil {
+		return err
+	}
+	return s.repo.RevokeExecutionShare(ctx, execId)
+}
+
+// ViewShared opens the execution a share token belongs to
+func (s *ExecutionService) ViewShared(ctx context.Context, token string) (*model.SharedExecution, error) {
+	exec, err := s.repo.GetSharedExecution(ctx, hashShareToken(token))
+	if err != nil {
+		return nil, err
+	}
+	return s.sharedView(ctx, exec)
+}
+
+// ViewAsFollower opens an execution shared with the tourist's followers for one of them. Executions
+// that are not shared that way read as not found, so viewers cannot probe for them.
+func (s *ExecutionService) ViewAsFollower(ctx context.Context, execId primitive.ObjectID, viewerId string) (*model.SharedExecution, error) {
+	exec, err := s.repo.GetExecutionByID(ctx, execId)
+	if err != nil {
+		return nil, err
+	}
+	share := exec.Share
+	if share == nil || !share.Followers || !share.ExpiresAt.After(time.Now()) ||
+		(exec.Status != model.ExecutionActive && exec.Status != model.ExecutionPaused) {
+		return nil, repository.ErrExecutionNotFound
+	}
+	if s.followers == nil {
+		return nil, repository.ErrForbidden
+	}
+	follows, err := s.followers.IsFollower(ctx, exec.TouristID, viewerId)
+	if err != nil {
+		return nil, err
+	}
+	if !follows {
+		return nil, repository.ErrForbidden
+	}
+	return s.sharedView(ctx, exec)
+}
+
+func (s *ExecutionService) sharedView(ctx context.Context, exec *model.TourExecution) (*model.SharedExecution, error) {
+	track, err := s.repo.GetExecutionTrack(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+	kps, err := s.keyPoints(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &model.SharedExecution{
+		ExecutionID:     exec.ID,
+		TourID:          exec.TourID,
+		TouristID:       exec.TouristID,
+		Status:          exec.Status,
+		StartedAt:       exec.StartedAt,
+		LastLocation:    exec.LastLocation,
+		Locations:       []model.Location{},
+		CompletedPoints: exec.CompletedPoints,
+		KeyPoints:       []model.KeyPoint{},
+		ExpiresAt:       exec.Share.ExpiresAt,
+	}
+	for _, loc := range track {
+		if !loc.Flagged {
+			view.Locations = append(view.Locations, loc)
+		}
+	}
+	reached := reachedKeyPoints(exec)
+	for _, kp := range kps {
+		if reached[kp.ID] {
+			view.KeyPoints = append(view.KeyPoints, kp)
+		}
+	}
+	if len(kps) > 0 {
+		view.Progress = math.Round(float64(len(view.KeyPoints))/float64(len(kps))*1000) / 10
+	}
+	return view, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	DefaultIdleTimeout   = 2 * time.Hour
+	DefaultPauseTimeout  = 12 * time.Hour
+	DefaultSweepInterval = time.Minute
+)
+
+var abandonedExecutions = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "tour_executions_abandoned_total",
+	Help: "Active or paused tour executions marked abandoned after going idle.",
+})
+
+type staleExecutionRepository interface {
+	AbandonStaleExecutions(ctx context.Context, cutoff, pausedCutoff time.Time) (int64, error)
+	CloseExpiredGroupSessions(ctx context.Context, now time.Time) (int64, error)
+}
+
+// ExecutionSweeper abandons executions whose tourist stopped sending activity, so a closed app
+// does not keep blocking them from starting another tour. Paused executions get the longer pause
+// timeout, counted from when they were paused. It also closes group sessions past their expiry.
+type ExecutionSweeper struct {
+	repo     staleExecutionRepository
+	idle     time.Duration
+	paused   time.Duration
+	interval time.Duration
+}
+
+func NewExecutionSweeper(repo staleExecutionRepository, idle, paused, interval time.Duration) *ExecutionSweeper {
+	if idle <= 0 {
+		idle = DefaultIdleTimeout
+	}
+	if paused <= 0 {
+		paused = DefaultPauseTimeout
+	}
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+	return &ExecutionSweeper{repo: repo, idle: idle, paused: paused, interval: interval}
+}
+
+// Run sweeps once right away and then every interval until ctx is cancelled
+func (s *ExecutionSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.sweep(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ExecutionSweeper) sweep(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	n, err := s.repo.AbandonStaleExecutions(ctx, now.Add(-s.idle), now.Add(-s.paused))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Println("abandon stale executions error:", err)
+		}
+		return
+	}
+	if n > 0 {
+		abandonedExecutions.Add(float64(n))
+		log.Printf("abandoned %d executions idle for more than %s or paused for more than %s", n, s.idle, s.paused)
+	}
+
+	closed, err := s.repo.CloseExpiredGroupSessions(ctx, now)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Println("close expired group sessions error:", err)
+		}
+		return
+	}
+	if closed > 0 {
+		log.Printf("closed %d expired group sessions", closed)
+	}
+}
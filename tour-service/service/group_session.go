@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"tour-service/model"
+	"tour-service/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// DefaultGroupSessionTTL is how long a session takes new members unless its guide closes it sooner
+	DefaultGroupSessionTTL = 8 * time.Hour
+	DefaultGroupSize       = 30
+	MaxGroupSize           = 100
+)
+
+// StartGroup opens a guided session of a published tour for its author, pinned to the tour's current
+// version. It takes at most maxMembers tourists, DefaultGroupSize when zero, and closes by itself
+// after DefaultGroupSessionTTL.
+func (s *ExecutionService) StartGroup(ctx context.Context, guideId string, tourId string, maxMembers int) (*model.GroupSession, error) {
+	if maxMembers == 0 {
+		maxMembers = DefaultGroupSize
+	}
+	if maxMembers < 0 || maxMembers > MaxGroupSize {
+		return nil, ErrInvalidGroupSize
+	}
+	tour, err := s.startableTour(ctx, tourId)
+	if err != nil {
+		return nil, err
+	}
+	if tour.AuthorID != guideId {
+		return nil, repository.ErrForbidden
+	}
+	return s.repo.CreateGroupSession(ctx, &model.GroupSession{
+		TourID:      tour.ID,
+		GuideID:     guideId,
+		TourVersion: tour.CurrentVersion,
+		MaxMembers:  maxMembers,
+		ExpiresAt:   time.Now().UTC().Add(DefaultGroupSessionTTL),
+	})
+}
+
+// JoinGroup starts the tourist's execution of an open session's tour. The guide invited them with
+// the code, so the tour does not have to be bought; the session's expiry and member cap bound how
+// many tourists that lets in.
+func (s *ExecutionService) JoinGroup(ctx context.Context, userId string, code string, mode model.TransportMode) (*model.TourExecution, error) {
+	mode, err := transportMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	g, err := s.repo.JoinGroupSession(ctx, strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil {
+		return nil, err
+	}
+	exec, err := s.joinedExecution(ctx, g, userId, mode)
+	if err != nil {
+		// give the seat back to the next tourist
+		if lerr := s.repo.LeaveGroupSession(ctx, g.ID); lerr != nil {
+			log.Println("release group seat error:", lerr)
+		}
+		return nil, err
+	}
+	return exec, nil
+}
+
+func (s *ExecutionService) joinedExecution(ctx context.Context, g *model.GroupSession, userId string, mode model.TransportMode) (*model.TourExecution, error) {
+	tour, err := s.startableTour(ctx, g.TourID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	// members follow the version the session was pinned to, not whatever was published since
+	sequential := tour.SequentialKeyPoints
+	if g.TourVersion != 0 {
+		v, err := s.repo.GetTourVersion(ctx, g.TourID, g.TourVersion)
+		if err != nil {
+			return nil, err
+		}
+		sequential = v.Tour.SequentialKeyPoints
+	}
+
+	return s.repo.CreateExecution(ctx, &model.TourExecution{
+		TourID:              tour.ID,
+		TouristID:           userId,
+		TourVersion:         g.TourVersion,
+		SequentialKeyPoints: sequential,
+		TransportMode:       mode,
+		GroupID:             &g.ID,
+		Status:              model.ExecutionActive,
+		LastActivity:        time.Now().UTC(),
+	})
+}
+
+// guidedGroup loads a session for its guide along with the key points its members follow
+func (s *ExecutionService) guidedGroup(ctx context.Context, groupId primitive.ObjectID, guideId string) (*model.GroupSession, []model.KeyPoint, error) {
+	g, err := s.repo.GetGroupSession(ctx, groupId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if g.GuideID != guideId {
+		return nil, nil, repository.ErrForbidden
+	}
+	kps, err := s.repo.GetExecutionKeyPoints(ctx, &model.TourExecution{TourID: g.TourID, TourVersion: g.TourVersion})
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, kps, nil
+}
+
+// GetGroup shows the guide where every member of the session last was and which key points they reached
+func (s *ExecutionService) GetGroup(ctx context.Context, groupId primitive.ObjectID, guideId string) (*model.GroupView, error) {
+	g, kps, err := s.guidedGroup(ctx, groupId, guideId)
+	if err != nil {
+		return nil, err
+	}
+	execs, err := s.repo.GetGroupExecutions(ctx, g.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &model.GroupView{GroupSession: *g, Members: make([]model.GroupMember, 0, len(execs))}
+	for i := range execs {
+		exec := &execs[i]
+		member := model.GroupMember{
+			TouristID:       exec.TouristID,
+			ExecutionID:     exec.ID,
+			Status:          exec.Status,
+			LastLocation:    exec.LastLocation,
+			CompletedPoints: exec.CompletedPoints,
+			LastActivity:    exec.LastActivity,
+		}
+		if len(kps) > 0 {
+			reached := reachedKeyPoints(exec)
+			n := 0
+			for _, kp := range kps {
+				if reached[kp.ID] {
+					n++
+				}
+			}
+			member.Progress = math.Round(float64(n)/float64(len(kps))*1000) / 10
+		}
+		view.Members = append(view.Members, member)
+	}
+	return view, nil
+}
+
+// CloseGroup stops new tourists from joining; members already walking carry on
+func (s *ExecutionService) CloseGroup(ctx context.Context, groupId primitive.ObjectID, guideId string) error {
+	if _, _, err := s.guidedGroup(ctx, groupId, guideId); err != nil {
+		return err
+	}
+	return s.repo.CloseGroupSession(ctx, groupId)
+}
+
+// CompleteGroupPoint marks a key point reached for every active member at once. The guide vouches
+// for the group, so neither positions nor the order of sequential key points are checked. It returns
+// the events of every member, including the completion of executions that reached their last key point.
+func (s *ExecutionService) CompleteGroupPoint(ctx context.Context, groupId primitive.ObjectID, guideId string, kpId primitive.ObjectID) ([]model.ExecutionEvent, error) {
+	g, kps, err := s.guidedGroup(ctx, groupId, guideId)
+	if err != nil {
+		return nil, err
+	}
+	var keypoint *model.KeyPoint
+	for i := range kps {
+		if kps[i].ID == kpId {
+			keypoint = &kps[i]
+			break
+		}
+	}
+	if keypoint == nil {
+		return nil, ErrKeyPointNotInTour
+	}
+
+	cp := model.CompletedPoint{KeyPointID: kpId, ReachedAt: time.Now().UTC()}
+	execs, err := s.repo.CompleteGroupPoint(ctx, g.ID, cp)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []model.ExecutionEvent{}
+	for i := range execs {
+		exec := &execs[i]
+		events = append(events, model.ExecutionEvent{
+			Type:        model.EventKeyPointReached,
+			ExecutionID: exec.ID,
+			KeyPoint:    keypoint,
+			Timestamp:   cp.ReachedAt,
+		})
+		done, err := s.finishIfComplete(ctx, exec, kps, reachedKeyPoints(exec))
+		if err != nil {
+			return events, err
+		}
+		if done != nil {
+			events = append(events, *done)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"tour-service/model"
+	"tour-service/utils"
+)
+
+// maxSpeedKmh is the fastest a tourist can plausibly move in each transport mode
+var maxSpeedKmh = map[model.TransportMode]float64{
+	model.TransportWalking: 20,
+	model.TransportBiking:  60,
+	model.TransportDriving: 180,
+}
+
+const (
+	// a jump longer than teleportDistance at more than teleportSpeedKmh is no ground movement at all
+	teleportDistance = 1000.0
+	teleportSpeedKmh = 500.0
+	// accuracy assumed for fixes that did not report one, in meters
+	defaultFixAccuracy = 10.0
+	// a fix closer than minTrackSpacing meters to the previous stored one is dropped from the track,
+	// unless maxTrackGap passed since, which keeps a tourist standing still to one fix a minute
+	minTrackSpacing = 10.0
+	maxTrackGap     = time.Minute
+)
+
+// fix is a position on the execution's timeline; fresh marks the ones being recorded now
+type fix struct {
+	loc   *model.Location
+	fresh bool
+}
+
+// timeline merges the recorded track with fresh positions in timestamp order. Flagged fixes are
+// left out so that one spoofed position does not make the way back to reality look like a jump.
+func timeline(recorded []model.Location, fresh []model.Location) []fix {
+	fixes := make([]fix, 0, len(recorded)+len(fresh))
+	for i := range recorded {
+		if !recorded[i].Flagged {
+			fixes = append(fixes, fix{loc: &recorded[i]})
+		}
+	}
+	for i := range fresh {
+		fixes = append(fixes, fix{loc: &fresh[i], fresh: true})
+	}
+	sort.SliceStable(fixes, func(i, j int) bool { return fixes[i].loc.Timestamp.Before(fixes[j].loc.Timestamp) })
+	return fixes
+}
+
+func fixAccuracy(loc *model.Location) float64 {
+	if loc.Accuracy != nil {
+		return *loc.Accuracy
+	}
+	return defaultFixAccuracy
+}
+
+// screenMovement compares every fresh fix with the last plausible fix before it and flags the ones
+// that moved faster than the transport mode allows. The accuracy radius of both fixes is granted
+// as slack so GPS jitter between close fixes is not mistaken for speed.
+func screenMovement(fixes []fix, mode model.TransportMode) []model.MovementAnomaly {
+	limit, ok := maxSpeedKmh[mode]
+	if !ok {
+		limit = maxSpeedKmh[model.TransportWalking]
+	}
+
+	var anomalies []model.MovementAnomaly
+	var prev *model.Location
+	for _, f := range fixes {
+		if prev == nil || !f.fresh {
+			prev = f.loc
+			continue
+		}
+		dist := utils.HaversineDistance(prev.Latitude, prev.Longitude, f.loc.Latitude, f.loc.Longitude)
+		moved := dist - fixAccuracy(prev) - fixAccuracy(f.loc)
+		if moved <= 0 {
+			prev = f.loc
+			continue
+		}
+		speed := math.Inf(1)
+		if dt := f.loc.Timestamp.Sub(prev.Timestamp).Seconds(); dt > 0 {
+			speed = moved / dt * 3.6
+		}
+		if speed <= limit {
+			prev = f.loc
+			continue
+		}
+
+		anomaly := model.MovementAnomaly{
+			Type:      model.AnomalyOverSpeed,
+			At:        f.loc.Timestamp,
+			Distance:  math.Round(dist),
+			Latitude:  f.loc.Latitude,
+			Longitude: f.loc.Longitude,
+		}
+		if !math.IsInf(speed, 1) {
+			anomaly.SpeedKmh = math.Round(speed*10) / 10
+		}
+		if dist > teleportDistance && speed > teleportSpeedKmh {
+			anomaly.Type = model.AnomalyTeleport
+		}
+		f.loc.Flagged = true
+		anomalies = append(anomalies, anomaly)
+	}
+	return anomalies
+}
+
+// downsample picks the fresh fixes worth storing, comparing each with the last stored fix before it.
+// Flagged fixes are always kept as the evidence behind the execution's anomalies.
+func downsample(fixes []fix) []model.Location {
+	var stored []model.Location
+	var last *model.Location
+	for _, f := range fixes {
+		switch {
+		case !f.fresh:
+			last = f.loc
+			continue
+		case f.loc.Flagged:
+			stored = append(stored, *f.loc)
+			continue
+		case last != nil && f.loc.Timestamp.Sub(last.Timestamp) < maxTrackGap &&
+			utils.HaversineDistance(last.Latitude, last.Longitude, f.loc.Latitude, f.loc.Longitude) < minTrackSpacing:
+			continue
+		}
+		stored = append(stored, *f.loc)
+		last = f.loc
+	}
+	return stored
+}
+
+// dwellTracker reports when a tourist has stayed inside a key point's area long enough to count as there
+type dwellTracker struct {
+	dwell   time.Duration
+	entered map[int]time.Time // key point index -> timestamp of the first fix of the current stay
+}
+
+func newDwellTracker(dwell time.Duration) *dwellTracker {
+	return &dwellTracker{dwell: dwell, entered: make(map[int]time.Time)}
+}
+
+// observe feeds a fix for key point i and reports whether the stay it belongs to is long enough
+func (d *dwellTracker) observe(i int, kp model.KeyPoint, loc *model.Location) bool {
+	if !utils.InKeyPointArea(loc.Latitude, loc.Longitude, kp) {
+		delete(d.entered, i)
+		return false
+	}
+	since, ok := d.entered[i]
+	if !ok {
+		since = loc.Timestamp
+		d.entered[i] = since
+	}
+	return loc.Timestamp.Sub(since) >= d.dwell
+}
+
+// summarize measures the way covered between startedAt and finishedAt over the plausible fixes
+// accurate enough to complete key points, so jitter of coarse fixes does not add up to distance.
+// Pauses count until finishedAt and are left out of the duration and the pace.
+func summarize(fixes []fix, startedAt, finishedAt time.Time, pauses []model.PauseInterval) model.ExecutionSummary {
+	var dist float64
+	var prev *model.Location
+	for _, f := range fixes {
+		if f.loc.Flagged || fixAccuracy(f.loc) > maxAutoCompleteAccuracy || f.loc.Timestamp.After(finishedAt) {
+			continue
+		}
+		if prev != nil {
+			dist += utils.HaversineDistance(prev.Latitude, prev.Longitude, f.loc.Latitude, f.loc.Longitude)
+		}
+		prev = f.loc
+	}
+
+	var paused time.Duration
+	for _, p := range pauses {
+		end := finishedAt
+		if p.End != nil && p.End.Before(end) {
+			end = *p.End
+		}
+		if end.After(p.Start) {
+			paused += end.Sub(p.Start)
+		}
+	}
+	duration := finishedAt.Sub(startedAt) - paused
+	if duration < 0 {
+		duration = 0
+	}
+	summary := model.ExecutionSummary{
+		DurationSeconds: int64(duration.Seconds()),
+		PausedSeconds:   int64(paused.Seconds()),
+		DistanceMeters:  math.Round(dist),
+	}
+	if summary.DistanceMeters > 0 {
+		summary.AvgPaceMinPerKm = math.Round(duration.Minutes()/(dist/1000)*100) / 100
+	}
+	return summary
+}
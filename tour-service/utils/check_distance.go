@@ -20,10 +20,13 @@ func HaversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	return R * c
 }
 
-// threshold in meters to consider a tourist "near" a key point
+// default radius in meters within which a tourist is "at" a key point
 const KeyPointThreshold = 30.0
 
-func IsNearby(lat1, lon1, lat2, lon2 float64) bool {
-	distance := HaversineDistance(lat1, lon1, lat2, lon2)
-	return distance <= KeyPointThreshold
+// distance in meters from the route after which a tourist is considered off route
+const OffRouteThreshold = 100.0
+
+// ValidCoordinates reports whether lat/lon are inside the WGS84 ranges.
+func ValidCoordinates(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 && !math.IsNaN(lat) && !math.IsNaN(lon)
 }
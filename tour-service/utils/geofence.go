@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"errors"
+
+	"tour-service/model"
+)
+
+// bounds for the radius a guide may give a key point, in meters
+const (
+	MinKeyPointRadius = 5.0
+	MaxKeyPointRadius = 2000.0
+
+	maxGeofencePositions = 500
+)
+
+var (
+	ErrInvalidRadius   = errors.New("radius must be between 5 and 2000 meters")
+	ErrInvalidGeofence = errors.New("geofence must be a closed polygon ring of 4 to 500 valid [longitude, latitude] positions")
+)
+
+// CheckKeyPointArea validates the radius and geofence set on a key point; 0 and nil mean none
+func CheckKeyPointArea(radius float64, fence *model.GeoPolygon) error {
+	if radius != 0 && (radius < MinKeyPointRadius || radius > MaxKeyPointRadius) {
+		return ErrInvalidRadius
+	}
+	if fence == nil {
+		return nil
+	}
+	if fence.Type != "Polygon" || len(fence.Coordinates) == 0 {
+		return ErrInvalidGeofence
+	}
+	ring := fence.Coordinates[0]
+	if len(ring) < 4 || len(ring) > maxGeofencePositions {
+		return ErrInvalidGeofence
+	}
+	for _, pos := range ring {
+		if len(pos) < 2 || !ValidCoordinates(pos[1], pos[0]) {
+			return ErrInvalidGeofence
+		}
+	}
+	first, last := ring[0], ring[len(ring)-1]
+	if first[0] != last[0] || first[1] != last[1] {
+		return ErrInvalidGeofence
+	}
+	return nil
+}
+
+// InKeyPointArea reports whether a position counts as being at the key point: inside its geofence
+// if it has one, otherwise within its radius, or within KeyPointThreshold when it has none
+func InKeyPointArea(lat, lng float64, kp model.KeyPoint) bool {
+	if kp.Geofence != nil && len(kp.Geofence.Coordinates) > 0 {
+		return pointInRing(lat, lng, kp.Geofence.Coordinates[0])
+	}
+	radius := kp.Radius
+	if radius <= 0 {
+		radius = KeyPointThreshold
+	}
+	return HaversineDistance(lat, lng, kp.Latitude, kp.Longitude) <= radius
+}
+
+// pointInRing casts a ray east of the position and counts how many ring edges it crosses
+func pointInRing(lat, lng float64, ring [][]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lng < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"tour-service/model"
+)
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *geoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// ParseGeoJSON reads the Point features of a FeatureCollection as key points in feature order.
+// The name, description and imageUrl properties are copied; other geometries are skipped.
+func ParseGeoJSON(r io.Reader) ([]model.KeyPoint, error) {
+	var fc geoJSONFeatureCollection
+	if err := json.NewDecoder(r).Decode(&fc); err != nil || fc.Type != "FeatureCollection" {
+		return nil, ErrInvalidRouteFile
+	}
+	var kps []model.KeyPoint
+	for i, f := range fc.Features {
+		if f.Geometry == nil || f.Geometry.Type != "Point" {
+			continue
+		}
+		var coords []float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &coords); err != nil || len(coords) < 2 {
+			return nil, fmt.Errorf("feature %d: %w", i, ErrInvalidCoordinates)
+		}
+		kps = append(kps, model.KeyPoint{
+			Name:        stringProperty(f.Properties, "name"),
+			Description: stringProperty(f.Properties, "description"),
+			ImageURL:    stringProperty(f.Properties, "imageUrl"),
+			Latitude:    coords[1],
+			Longitude:   coords[0],
+		})
+	}
+	return checkImportedKeyPoints(kps)
+}
+
+func stringProperty(props map[string]interface{}, key string) string {
+	if v, ok := props[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// EncodeGeoJSON writes the tour as a FeatureCollection: one Point feature per key point
+// followed by a LineString feature for the route carrying the tour properties
+func EncodeGeoJSON(w io.Writer, tour *model.Tour, kps []model.KeyPoint) error {
+	fc := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	line := make([][]float64, 0, len(kps))
+	for _, kp := range kps {
+		coords := []float64{kp.Longitude, kp.Latitude}
+		line = append(line, coords)
+		raw, _ := json.Marshal(coords)
+		props := map[string]interface{}{"name": kp.Name, "order": kp.Order}
+		if kp.Description != "" {
+			props["description"] = kp.Description
+		}
+		if kp.ImageURL != "" {
+			props["imageUrl"] = kp.ImageURL
+		}
+		fc.Features = append(fc.Features, geoJSONFeature{
+			Type:       "Feature",
+			Geometry:   &geoJSONGeometry{Type: "Point", Coordinates: raw},
+			Properties: props,
+		})
+	}
+	if len(line) > 1 {
+		raw, _ := json.Marshal(line)
+		fc.Features = append(fc.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: &geoJSONGeometry{Type: "LineString", Coordinates: raw},
+			Properties: map[string]interface{}{
+				"name":        tour.Name,
+				"description": tour.Description,
+				"difficulty":  tour.Difficulty,
+				"tags":        tour.Tags,
+				"distance":    tour.Distance,
+				"durations":   tour.Durations,
+			},
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fc)
+}
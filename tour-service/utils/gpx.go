@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	"tour-service/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type gpxDoc struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Xmlns     string        `xml:"xmlns,attr,omitempty"`
+	Metadata  *gpxMetadata  `xml:"metadata,omitempty"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+	Routes    []gpxRoute    `xml:"rte"`
+	Tracks    []gpxTrack    `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name string     `xml:"name,omitempty"`
+	Desc string     `xml:"desc,omitempty"`
+	Time *time.Time `xml:"time,omitempty"`
+}
+
+type gpxWaypoint struct {
+	Lat  float64    `xml:"lat,attr"`
+	Lon  float64    `xml:"lon,attr"`
+	Time *time.Time `xml:"time,omitempty"`
+	Name string     `xml:"name,omitempty"`
+	Desc string     `xml:"desc,omitempty"`
+	Link *gpxLink   `xml:"link,omitempty"`
+}
+
+type gpxLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type gpxRoute struct {
+	Name   string        `xml:"name,omitempty"`
+	Points []gpxWaypoint `xml:"rtept"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxWaypoint `xml:"trkpt"`
+}
+
+// ParseGPX reads the waypoints of a GPX file as key points in document order.
+// Files without waypoints fall back to the points of their routes.
+func ParseGPX(r io.Reader) ([]model.KeyPoint, error) {
+	var doc gpxDoc
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, ErrInvalidRouteFile
+	}
+	points := doc.Waypoints
+	if len(points) == 0 {
+		for _, rte := range doc.Routes {
+			points = append(points, rte.Points...)
+		}
+	}
+	kps := make([]model.KeyPoint, 0, len(points))
+	for _, p := range points {
+		kp := model.KeyPoint{
+			Name:        p.Name,
+			Description: p.Desc,
+			Latitude:    p.Lat,
+			Longitude:   p.Lon,
+		}
+		if p.Link != nil {
+			kp.ImageURL = p.Link.Href
+		}
+		kps = append(kps, kp)
+	}
+	return checkImportedKeyPoints(kps)
+}
+
+// EncodeGPX writes the tour as a GPX 1.1 document with one waypoint per key point
+func EncodeGPX(w io.Writer, tour *model.Tour, kps []model.KeyPoint) error {
+	doc := gpxDoc{
+		Version:  "1.1",
+		Creator:  "tour-service",
+		Xmlns:    "http://www.topografix.com/GPX/1/1",
+		Metadata: &gpxMetadata{Name: tour.Name, Desc: tour.Description},
+	}
+	for _, kp := range kps {
+		wpt := gpxWaypoint{Lat: kp.Latitude, Lon: kp.Longitude, Name: kp.Name, Desc: kp.Description}
+		if kp.ImageURL != "" {
+			wpt.Link = &gpxLink{Href: kp.ImageURL}
+		}
+		doc.Waypoints = append(doc.Waypoints, wpt)
+	}
+	return writeXML(w, doc)
+}
+
+// EncodeExecutionGPX writes an execution as a GPX 1.1 document: its plausible positions as one
+// track and every key point it reached as a waypoint stamped with when it was reached
+func EncodeExecutionGPX(w io.Writer, tourName string, exec *model.TourExecution, kps []model.KeyPoint) error {
+	started := exec.StartedAt
+	doc := gpxDoc{
+		Version:  "1.1",
+		Creator:  "tour-service",
+		Xmlns:    "http://www.topografix.com/GPX/1/1",
+		Metadata: &gpxMetadata{Name: tourName, Time: &started},
+	}
+
+	byID := make(map[primitive.ObjectID]model.KeyPoint, len(kps))
+	for _, kp := range kps {
+		byID[kp.ID] = kp
+	}
+	for _, cp := range exec.CompletedPoints {
+		kp, ok := byID[cp.KeyPointID]
+		if !ok {
+			continue
+		}
+		// a key point completed twice is shown once
+		delete(byID, cp.KeyPointID)
+		reachedAt := cp.ReachedAt
+		doc.Waypoints = append(doc.Waypoints, gpxWaypoint{
+			Lat:  kp.Latitude,
+			Lon:  kp.Longitude,
+			Time: &reachedAt,
+			Name: kp.Name,
+			Desc: kp.Description,
+		})
+	}
+
+	var seg gpxSegment
+	for _, loc := range exec.Locations {
+		if loc.Flagged {
+			continue
+		}
+		ts := loc.Timestamp
+		seg.Points = append(seg.Points, gpxWaypoint{Lat: loc.Latitude, Lon: loc.Longitude, Time: &ts})
+	}
+	if len(seg.Points) > 0 {
+		doc.Tracks = []gpxTrack{{Name: tourName, Segments: []gpxSegment{seg}}}
+	}
+	return writeXML(w, doc)
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"tour-service/model"
+)
+
+type kmlDoc struct {
+	XMLName  xml.Name    `xml:"kml"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name        string         `xml:"name,omitempty"`
+	Description string         `xml:"description,omitempty"`
+	Placemarks  []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string         `xml:"name,omitempty"`
+	Description string         `xml:"description,omitempty"`
+	Point       *kmlPoint      `xml:"Point,omitempty"`
+	LineString  *kmlLineString `xml:"LineString,omitempty"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// ParseKML reads every point placemark of a KML file as a key point in document order.
+// Placemarks may be nested in folders; placemarks without a point geometry are skipped.
+func ParseKML(r io.Reader) ([]model.KeyPoint, error) {
+	dec := xml.NewDecoder(r)
+	var kps []model.KeyPoint
+	sawKML := false
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidRouteFile
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "kml":
+			sawKML = true
+		case "Placemark":
+			var pm kmlPlacemark
+			if err := dec.DecodeElement(&pm, &start); err != nil {
+				return nil, ErrInvalidRouteFile
+			}
+			if pm.Point == nil {
+				continue
+			}
+			lat, lng, err := parseKMLCoordinate(pm.Point.Coordinates)
+			if err != nil {
+				return nil, fmt.Errorf("placemark %q: %w", pm.Name, err)
+			}
+			kps = append(kps, model.KeyPoint{
+				Name:        pm.Name,
+				Description: strings.TrimSpace(pm.Description),
+				Latitude:    lat,
+				Longitude:   lng,
+			})
+		}
+	}
+	if !sawKML {
+		return nil, ErrInvalidRouteFile
+	}
+	return checkImportedKeyPoints(kps)
+}
+
+// parseKMLCoordinate parses a "lon,lat[,alt]" tuple
+func parseKMLCoordinate(s string) (lat, lng float64, err error) {
+	parts := strings.Split(strings.TrimSpace(s), ",")
+	if len(parts) < 2 {
+		return 0, 0, ErrInvalidCoordinates
+	}
+	lng, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return 0, 0, ErrInvalidCoordinates
+	}
+	lat, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return 0, 0, ErrInvalidCoordinates
+	}
+	return lat, lng, nil
+}
+
+// EncodeKML writes the tour as a KML document with one placemark per key point and the route as a line
+func EncodeKML(w io.Writer, tour *model.Tour, kps []model.KeyPoint) error {
+	doc := kmlDoc{
+		Xmlns:    "http://www.opengis.net/kml/2.2",
+		Document: kmlDocument{Name: tour.Name, Description: tour.Description},
+	}
+	coords := make([]string, 0, len(kps))
+	for _, kp := range kps {
+		c := kmlCoordinate(kp.Latitude, kp.Longitude)
+		coords = append(coords, c)
+		doc.Document.Placemarks = append(doc.Document.Placemarks, kmlPlacemark{
+			Name:        kp.Name,
+			Description: kp.Description,
+			Point:       &kmlPoint{Coordinates: c},
+		})
+	}
+	if len(coords) > 1 {
+		doc.Document.Placemarks = append(doc.Document.Placemarks, kmlPlacemark{
+			Name:       tour.Name,
+			LineString: &kmlLineString{Coordinates: strings.Join(coords, " ")},
+		})
+	}
+	return writeXML(w, doc)
+}
+
+func kmlCoordinate(lat, lng float64) string {
+	return strconv.FormatFloat(lng, 'f', -1, 64) + "," + strconv.FormatFloat(lat, 'f', -1, 64)
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"math"
+	"os"
+	"strconv"
+
+	"tour-service/model"
+)
+
+// TravelSpeeds are the average speeds in km/h used to estimate tour durations
+type TravelSpeeds struct {
+	Walking float64
+	Biking  float64
+	Driving float64
+}
+
+func DefaultTravelSpeeds() TravelSpeeds {
+	return TravelSpeeds{Walking: 5, Biking: 15, Driving: 40}
+}
+
+// TravelSpeedsFromEnv reads WALKING_SPEED_KMH, BIKING_SPEED_KMH and DRIVING_SPEED_KMH,
+// keeping the default for any variable that is unset or not a positive number
+func TravelSpeedsFromEnv() TravelSpeeds {
+	s := DefaultTravelSpeeds()
+	s.Walking = speedFromEnv("WALKING_SPEED_KMH", s.Walking)
+	s.Biking = speedFromEnv("BIKING_SPEED_KMH", s.Biking)
+	s.Driving = speedFromEnv("DRIVING_SPEED_KMH", s.Driving)
+	return s
+}
+
+func speedFromEnv(name string, def float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(name), 64)
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+// Estimate converts a route length in kilometers to whole minutes per transport mode, rounding up
+func (s TravelSpeeds) Estimate(distanceKm float64) model.TransportDuration {
+	return model.TransportDuration{
+		Walking: minutes(distanceKm, s.Walking),
+		Biking:  minutes(distanceKm, s.Biking),
+		Driving: minutes(distanceKm, s.Driving),
+	}
+}
+
+func minutes(distanceKm, speedKmh float64) int {
+	if distanceKm <= 0 || speedKmh <= 0 {
+		return 0
+	}
+	return int(math.Ceil(distanceKm / speedKmh * 60))
+}
+
+// RouteDistance returns the length in kilometers of the path through the key points in the given order
+func RouteDistance(kps []model.KeyPoint) float64 {
+	total := 0.0
+	for i := 1; i < len(kps); i++ {
+		total += HaversineDistance(kps[i-1].Latitude, kps[i-1].Longitude, kps[i].Latitude, kps[i].Longitude)
+	}
+	// meters -> kilometers, rounded to 10 m
+	return math.Round(total/10) / 100
+}
+
+// DistanceToRoute returns the distance in meters from a position to the closest point of the path
+// through the key points. A single key point is treated as the whole route; no key points give 0.
+func DistanceToRoute(lat, lng float64, kps []model.KeyPoint) float64 {
+	switch len(kps) {
+	case 0:
+		return 0
+	case 1:
+		return HaversineDistance(lat, lng, kps[0].Latitude, kps[0].Longitude)
+	}
+	best := math.Inf(1)
+	for i := 1; i < len(kps); i++ {
+		best = math.Min(best, distanceToSegment(lat, lng, kps[i-1], kps[i]))
+	}
+	return best
+}
+
+// distanceToSegment projects the segment onto a flat plane centered on the position,
+// which is accurate enough at the scale of a walking tour
+func distanceToSegment(lat, lng float64, a, b model.KeyPoint) float64 {
+	const metersPerDegree = 6371000.0 * math.Pi / 180
+	scale := math.Cos(lat * math.Pi / 180)
+	ax, ay := (a.Longitude-lng)*scale*metersPerDegree, (a.Latitude-lat)*metersPerDegree
+	bx, by := (b.Longitude-lng)*scale*metersPerDegree, (b.Latitude-lat)*metersPerDegree
+
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
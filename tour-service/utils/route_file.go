@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+
+	"tour-service/model"
+)
+
+// Shared helpers for the GPX, KML and GeoJSON route formats
+
+var (
+	ErrInvalidRouteFile   = errors.New("invalid route file")
+	ErrInvalidCoordinates = errors.New("invalid coordinates")
+	ErrNoKeyPoints        = errors.New("file contains no points")
+	ErrTooManyKeyPoints   = errors.New("file contains too many points")
+)
+
+// MaxImportedKeyPoints caps how many key points a single import may create
+const MaxImportedKeyPoints = 500
+
+// checkImportedKeyPoints validates parsed points and names unnamed ones by position
+func checkImportedKeyPoints(kps []model.KeyPoint) ([]model.KeyPoint, error) {
+	if len(kps) == 0 {
+		return nil, ErrNoKeyPoints
+	}
+	if len(kps) > MaxImportedKeyPoints {
+		return nil, ErrTooManyKeyPoints
+	}
+	for i := range kps {
+		if !ValidCoordinates(kps[i].Latitude, kps[i].Longitude) {
+			return nil, fmt.Errorf("point %d: %w", i+1, ErrInvalidCoordinates)
+		}
+		if kps[i].Name == "" {
+			kps[i].Name = fmt.Sprintf("Point %d", i+1)
+		}
+	}
+	return kps, nil
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(v)
+}